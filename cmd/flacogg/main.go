@@ -0,0 +1,96 @@
+// Command flacogg converts FLAC between native files and Ogg, without decoding audio.
+//
+// Usage:
+//
+//	flacogg [-o output] [-serial n] file
+//
+// A native FLAC file is wrapped into Ogg, written to file.oga unless specified.
+// An Ogg FLAC file is unwrapped into native FLAC, written to file.flac unless specified.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sr8e/vorbis/flac"
+)
+
+func main() {
+	output := flag.String("o", "", "path to write converted file")
+	serial := flag.Uint("serial", 0, "serial number of Ogg stream to write")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: flacogg [-o output] [-serial n] file")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), *output, uint32(*serial)); err != nil {
+		fmt.Fprintln(os.Stderr, "flacogg:", err)
+		os.Exit(1)
+	}
+}
+
+func run(input, output string, serial uint32) error {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	native := bytes.HasPrefix(data, []byte("fLaC"))
+	var s *flac.Stream
+	if native {
+		s, err = flac.ReadNative(bytes.NewReader(data))
+	} else {
+		s, err = flac.ReadOgg(bytes.NewReader(data))
+	}
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		ext := ".flac"
+		if native {
+			ext = ".oga"
+		}
+		output = strings.TrimSuffix(input, filepath.Ext(input)) + ext
+	}
+	if output == input {
+		return fmt.Errorf("output %s overwrites input", output)
+	}
+
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	write := func(w io.Writer) error {
+		if native {
+			return s.WriteOgg(w, serial)
+		}
+		return s.WriteNative(w)
+	}
+	if err := write(w); err != nil {
+		out.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	samples := 0
+	for _, n := range s.BlockSizes {
+		samples += n
+	}
+	fmt.Printf("%d metadata blocks, %d frames, %d samples written to %s\n", len(s.Metadata), len(s.Frames), samples, output)
+	return nil
+}
@@ -0,0 +1,108 @@
+// Command oggcarve recovers Ogg Vorbis streams from arbitrary binary data, such as disk images.
+//
+// Usage:
+//
+//	oggcarve [-o dir] [-all] file
+//
+// Pages with valid checksum are collected by serial, and each stream with readable Vorbis headers
+// is written to dir as <serial>.ogg, or <serial>-<n>.ogg for the nth stream reusing a serial.
+// With -all, streams of any codec are written.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sr8e/vorbis/ogg"
+	"github.com/sr8e/vorbis/vorbis"
+)
+
+func main() {
+	outDir := flag.String("o", ".", "directory to write recovered streams")
+	all := flag.Bool("all", false, "write streams of any codec")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: oggcarve [-o dir] [-all] file")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *outDir, *all); err != nil {
+		fmt.Fprintln(os.Stderr, "oggcarve:", err)
+		os.Exit(1)
+	}
+}
+
+func run(path, outDir string, all bool) error {
+	fp, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	streams, err := ogg.Carve(bufio.NewReader(fp))
+	if err != nil {
+		return err
+	}
+
+	written := map[uint32]int{}
+	for i := range streams {
+		s := &streams[i]
+		vorbisErr := checkVorbis(s)
+		status := "vorbis"
+		if vorbisErr != nil {
+			status = "not vorbis: " + vorbisErr.Error()
+		}
+		fmt.Printf("%08x: %d pages, %d gaps, %s\n", s.Serial(), s.NumPages(), len(s.Gaps()), status)
+		if vorbisErr != nil && !all {
+			continue
+		}
+
+		name := fmt.Sprintf("%08x.ogg", s.Serial())
+		if n := written[s.Serial()]; n > 0 {
+			name = fmt.Sprintf("%08x-%d.ogg", s.Serial(), n)
+		}
+		written[s.Serial()]++
+		name = filepath.Join(outDir, name)
+		if err := writeStream(name, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkVorbis returns error unless headers of the stream are read as Vorbis.
+func checkVorbis(s *ogg.Stream) error {
+	packets, err := s.GetPackets()
+	if err != nil {
+		return err
+	}
+	if len(packets) < 3 {
+		return fmt.Errorf("only %d packets", len(packets))
+	}
+	vd := vorbis.VorbisDecoder{Packets: packets}
+	return vd.ReadHeaders()
+}
+
+func writeStream(name string, s *ogg.Stream) error {
+	fp, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(fp)
+	if _, err := s.WriteTo(w); err != nil {
+		fp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
@@ -0,0 +1,79 @@
+// Command oggfix repairs pages of an Ogg file, and reports what is changed.
+//
+// Usage:
+//
+//	oggfix [-o output] file
+//
+// Pages are read with a permissive parser, which accepts wrong checksums and skips garbage.
+// Sequence numbers are renumbered keeping gaps of lost pages, packets broken by lost pages are dropped,
+// beginning and end of stream flags are set, granule positions of Vorbis streams are recomputed
+// from block sizes, and checksums are recomputed.
+// The output is written to file.fixed.ogg unless specified.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sr8e/vorbis/ogg"
+	"github.com/sr8e/vorbis/vorbis"
+)
+
+func main() {
+	output := flag.String("o", "", "path to write repaired file")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: oggfix [-o output] file")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	input := flag.Arg(0)
+	if *output == "" {
+		*output = strings.TrimSuffix(input, ".ogg") + ".fixed.ogg"
+	}
+
+	if err := run(input, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "oggfix:", err)
+		os.Exit(1)
+	}
+}
+
+func run(input, output string) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+
+	changes, warnings, err := ogg.Fix(bufio.NewReader(in), w, vorbis.NewGranuleFunc)
+	for _, warning := range warnings {
+		fmt.Println(warning)
+	}
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	if err != nil {
+		out.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("%d changes, %d regions skipped\n", len(changes), len(warnings))
+	return nil
+}
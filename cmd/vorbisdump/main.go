@@ -0,0 +1,216 @@
+// Command vorbisdump prints the headers of the first Vorbis stream in an Ogg file.
+//
+// Usage:
+//
+//	vorbisdump [-json] [-v] [-packets] file
+//
+// The identification and comment headers are printed, followed by the setup header,
+// either as readable text or as JSON. With -packets, each audio packet is described
+// by its mode, block size, window flags, unused floors of each channel and bits consumed.
+// A malformed comment header is reported, and the rest is printed still, exiting with status 1.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sr8e/vorbis/vorbis"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print setup header as JSON")
+	verbose := flag.Bool("v", false, "print codeword lengths and VQ multiplicands")
+	packets := flag.Bool("packets", false, "describe each audio packet")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: vorbisdump [-json] [-v] [-packets] file")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	err := run(w, flag.Arg(0), *asJSON, *verbose, *packets)
+	w.Flush()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "vorbisdump:", err)
+		os.Exit(1)
+	}
+}
+
+func run(w io.Writer, path string, asJSON, verbose, packets bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	vd, err := vorbis.NewVorbisDecoder(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	if err := vd.ReadHeaders(); err != nil {
+		return err
+	}
+
+	if asJSON {
+		var commentErr string
+		if vd.CommentErr != nil {
+			commentErr = vd.CommentErr.Error()
+		}
+		b, err := json.MarshalIndent(struct {
+			Identification vorbis.Identification `json:"identification"`
+			Comment        vorbis.Comment        `json:"comment"`
+			CommentErr     string                `json:"comment_error,omitempty"`
+			Setup          vorbis.SetupInfo      `json:"setup"`
+		}{vd.Identification, vd.Comment, commentErr, vd.Setup().Info()}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\n", b)
+	} else {
+		printIdentification(w, vd.Identification)
+		printComment(w, vd.Comment, vd.CommentErr)
+		printSetup(w, vd.Setup().Info(), verbose)
+	}
+
+	if packets {
+		fmt.Fprintln(w, "packets:")
+		longBlock := 1 << vd.Identification.BlockExp[1]
+		err := vd.InspectPackets(func(info vorbis.PacketInfo) error {
+			printPacket(w, info, longBlock)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return vd.CommentErr
+}
+
+func printIdentification(w io.Writer, ident vorbis.Identification) {
+	fmt.Fprintln(w, "identification:")
+	fmt.Fprintf(w, "  channels: %d\n", ident.Channels)
+	fmt.Fprintf(w, "  sample rate: %d\n", ident.SampleRate)
+	fmt.Fprintf(w, "  bitrate: max %d, nominal %d, min %d\n", ident.BitRate[0], ident.BitRate[1], ident.BitRate[2])
+	fmt.Fprintf(w, "  block size: %d, %d\n", 1<<ident.BlockExp[0], 1<<ident.BlockExp[1])
+}
+
+func printComment(w io.Writer, comment vorbis.Comment, err error) {
+	fmt.Fprintln(w, "comment:")
+	if err != nil {
+		fmt.Fprintf(w, "  error: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "  vendor: %s\n", comment.Vendor)
+	for _, c := range comment.Comments {
+		fmt.Fprintf(w, "  %s\n", c)
+	}
+}
+
+func printSetup(w io.Writer, info vorbis.SetupInfo, verbose bool) {
+	fmt.Fprintf(w, "codebooks: %d\n", len(info.Codebooks))
+	for i, cb := range info.Codebooks {
+		used, maxLen := 0, 0
+		for _, l := range cb.Lengths {
+			if l > 0 {
+				used++
+				maxLen = max(maxLen, l)
+			}
+		}
+		fmt.Fprintf(w, "  [%d] dimension %d, %d entries (%d used), max length %d", i, cb.Dimension, cb.Entries, used, maxLen)
+		if cb.Ordered {
+			fmt.Fprint(w, ", ordered")
+		}
+		if cb.Sparse {
+			fmt.Fprint(w, ", sparse")
+		}
+		fmt.Fprintln(w)
+		if cb.Lookup != nil {
+			lu := cb.Lookup
+			fmt.Fprintf(w, "      lookup type %d, minimum %g, delta %g, %d bits, sequence %t, %d multiplicands\n",
+				lu.Type, lu.Minimum, lu.Delta, lu.ValueBits, lu.SequenceP, len(lu.Multiplicands))
+			if verbose {
+				fmt.Fprintf(w, "      multiplicands: %v\n", lu.Multiplicands)
+			}
+		}
+		if verbose {
+			fmt.Fprintf(w, "      lengths: %v\n", cb.Lengths)
+		}
+	}
+
+	fmt.Fprintf(w, "floors: %d\n", len(info.Floors))
+	for i, floor := range info.Floors {
+		fmt.Fprintf(w, "  [%d] type %d\n", i, floor.Type)
+		f1 := floor.Floor1
+		if f1 == nil {
+			continue
+		}
+		fmt.Fprintf(w, "      multiplier %d, range bits %d, partitions %v\n", f1.Multiplier, f1.RangeBits, f1.Partitions)
+		for j, cls := range f1.Classes {
+			fmt.Fprintf(w, "      class %d: dimension %d, subclass bits %d, master book %d, books %v\n",
+				j, cls.Dimension, cls.SubclassBits, cls.MasterBook, cls.SubclassBooks)
+		}
+		fmt.Fprintf(w, "      x list: %v\n", f1.XList)
+	}
+
+	fmt.Fprintf(w, "residues: %d\n", len(info.Residues))
+	for i, res := range info.Residues {
+		fmt.Fprintf(w, "  [%d] type %d, range [%d, %d), partition size %d, %d classifications, class book %d\n",
+			i, res.Type, res.Begin, res.End, res.PartitionSize, res.Classifications, res.ClassBook)
+		for j, books := range res.Books {
+			fmt.Fprintf(w, "      class %d: cascade %08b, books %v\n", j, res.Cascades[j], books)
+		}
+	}
+
+	fmt.Fprintf(w, "mappings: %d\n", len(info.Mappings))
+	for i, m := range info.Mappings {
+		coupling := make([]string, len(m.Coupling))
+		for j, c := range m.Coupling {
+			coupling[j] = fmt.Sprintf("%d/%d", c.Magnitude, c.Angle)
+		}
+		fmt.Fprintf(w, "  [%d] coupling [%s], mux %v\n", i, strings.Join(coupling, " "), m.Mux)
+		for j, sm := range m.Submaps {
+			fmt.Fprintf(w, "      submap %d: floor %d, residue %d\n", j, sm.Floor, sm.Residue)
+		}
+	}
+
+	fmt.Fprintf(w, "modes: %d\n", len(info.Modes))
+	for i, mode := range info.Modes {
+		fmt.Fprintf(w, "  [%d] long block %t, mapping %d\n", i, mode.BlockFlag, mode.Mapping)
+	}
+}
+
+func printPacket(w io.Writer, info vorbis.PacketInfo, longBlock int) {
+	if info.Err != nil {
+		fmt.Fprintf(w, "  %d: mode %d, %d/%d bits, error: %v\n", info.Index, info.Mode, info.Bits, info.Size, info.Err)
+		return
+	}
+	window := ""
+	if info.BlockSize == longBlock {
+		window = fmt.Sprintf(", window %s-%s", windowName(info.PrevWindow), windowName(info.NextWindow))
+	}
+	unused := make([]byte, len(info.Unused))
+	for ch, u := range info.Unused {
+		unused[ch] = '1'
+		if u {
+			unused[ch] = '0'
+		}
+	}
+	fmt.Fprintf(w, "  %d: mode %d, block %d%s, floors %s, %d/%d bits\n",
+		info.Index, info.Mode, info.BlockSize, window, unused, info.Bits, info.Size)
+}
+
+func windowName(long bool) string {
+	if long {
+		return "long"
+	}
+	return "short"
+}
@@ -29,3 +29,13 @@ func CRC32(value []byte, initMask uint32, finalMask uint32) uint32 {
 	}
 	return c ^ finalMask
 }
+
+// Update adds value to checksum c, computed directly without augmenting zero bytes.
+// Update(0, value) equals CRC32 of value followed by 4 zero bytes with no masks,
+// so that checksum of a message can be computed over separate pieces.
+func Update(c uint32, value []byte) uint32 {
+	for _, b := range value {
+		c = c<<8 ^ table[byte(c>>24)^b]
+	}
+	return c
+}
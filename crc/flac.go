@@ -0,0 +1,45 @@
+package crc
+
+// checksums of FLAC frames, in polynomials 0x07 for headers and 0x8005 for whole frames
+
+var table8 [256]uint8
+var table16 [256]uint16
+
+func init() {
+	for i := 0; i < 256; i++ {
+		c8 := uint8(i)
+		c16 := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if c8&0x80 != 0 {
+				c8 = c8<<1 ^ 0x07
+			} else {
+				c8 <<= 1
+			}
+			if c16&0x8000 != 0 {
+				c16 = c16<<1 ^ 0x8005
+			} else {
+				c16 <<= 1
+			}
+		}
+		table8[i] = c8
+		table16[i] = c16
+	}
+}
+
+// CRC8 returns the checksum of FLAC frame header.
+func CRC8(value []byte) uint8 {
+	var c uint8
+	for _, b := range value {
+		c = table8[c^b]
+	}
+	return c
+}
+
+// Update16 adds value to the checksum c of FLAC frame, which begins with 0.
+// The checksum of a whole frame including its footer is 0.
+func Update16(c uint16, value []byte) uint16 {
+	for _, b := range value {
+		c = c<<8 ^ table16[byte(c>>8)^b]
+	}
+	return c
+}
@@ -0,0 +1,170 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sr8e/vorbis/crc"
+)
+
+// FrameHeader is the header of audio frame. Fields coded as taken from STREAMINFO are 0.
+type FrameHeader struct {
+	// Variable is true for variable block size streams, whose frames are numbered by their first sample.
+	Variable      bool
+	BlockSize     int
+	SampleRate    uint32
+	Channels      uint8
+	BitsPerSample uint8
+	// Number is the frame number, or the sample number if Variable.
+	Number uint64
+	// Len is the length of the header in bytes, including its checksum.
+	Len int
+}
+
+var blockSizes = [16]int{0, 192, 576, 1152, 2304, 4608, 0, 0, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768}
+
+var sampleRates = [12]uint32{0, 88200, 176400, 192000, 8000, 16000, 22050, 24000, 32000, 44100, 48000, 96000}
+
+var sampleBits = [8]uint8{0, 8, 12, 0, 16, 20, 24, 32}
+
+// ParseFrameHeader parses the header at the beginning of data, validating its checksum.
+func ParseFrameHeader(data []byte) (FrameHeader, error) {
+	if len(data) < 4 {
+		return FrameHeader{}, errors.New("frame header is truncated")
+	}
+	if data[0] != 0xff || data[1]&0xfe != 0xf8 {
+		return FrameHeader{}, errors.New("frame sync code not found")
+	}
+	h := FrameHeader{Variable: data[1]&1 != 0}
+	bsCode := data[2] >> 4
+	srCode := data[2] & 0xf
+	chCode := data[3] >> 4
+	ssCode := data[3] >> 1 & 0x7
+	if bsCode == 0 || srCode == 15 || chCode > 10 || ssCode == 3 || data[3]&1 != 0 {
+		return FrameHeader{}, errors.New("reserved value in frame header")
+	}
+	h.BlockSize = blockSizes[bsCode]
+	if srCode < 12 {
+		h.SampleRate = sampleRates[srCode]
+	}
+	h.Channels = chCode + 1
+	if chCode >= 8 { // left/side, right/side, mid/side
+		h.Channels = 2
+	}
+	h.BitsPerSample = sampleBits[ssCode]
+
+	n, l, err := readCodedNumber(data[4:])
+	if err != nil {
+		return FrameHeader{}, err
+	}
+	if !h.Variable && l > 6 {
+		return FrameHeader{}, errors.New("frame number exceeds 31 bits")
+	}
+	h.Number = n
+	pos := 4 + l
+
+	// extra bytes of block size and sample rate follow
+	extra := func(n int) (int, error) {
+		if len(data) < pos+n {
+			return 0, errors.New("frame header is truncated")
+		}
+		v := 0
+		for _, b := range data[pos : pos+n] {
+			v = v<<8 | int(b)
+		}
+		pos += n
+		return v, nil
+	}
+	switch bsCode {
+	case 6, 7:
+		v, err := extra(int(bsCode) - 5)
+		if err != nil {
+			return FrameHeader{}, err
+		}
+		h.BlockSize = v + 1
+	}
+	switch srCode {
+	case 12, 13, 14:
+		v, err := extra(min(int(srCode)-11, 2))
+		if err != nil {
+			return FrameHeader{}, err
+		}
+		h.SampleRate = [...]uint32{1000, 1, 10}[srCode-12] * uint32(v)
+	}
+
+	if len(data) <= pos {
+		return FrameHeader{}, errors.New("frame header is truncated")
+	}
+	if crc.CRC8(data[:pos]) != data[pos] {
+		return FrameHeader{}, errors.New("frame header checksum mismatch")
+	}
+	h.Len = pos + 1
+	return h, nil
+}
+
+// readCodedNumber reads the number coded like UTF-8 in up to 7 bytes, and returns it with the bytes used.
+func readCodedNumber(data []byte) (uint64, int, error) {
+	if len(data) == 0 {
+		return 0, 0, errors.New("frame header is truncated")
+	}
+	b := data[0]
+	l := 1
+	for b<<l&0x80 != 0 {
+		l++
+	}
+	switch {
+	case b&0x80 == 0:
+		return uint64(b), 1, nil
+	case l == 1 || l > 7:
+		return 0, 0, fmt.Errorf("invalid coded number %#x", b)
+	}
+	if len(data) < l {
+		return 0, 0, errors.New("frame header is truncated")
+	}
+	v := uint64(b & (0x7f >> l))
+	for _, c := range data[1:l] {
+		if c&0xc0 != 0x80 {
+			return 0, 0, fmt.Errorf("invalid coded number %#x", c)
+		}
+		v = v<<6 | uint64(c&0x3f)
+	}
+	return v, l, nil
+}
+
+// splitFrames splits concatenated frames, each of which ends right before the next valid header
+// where the checksum of the frame matches.
+func splitFrames(data []byte) ([][]byte, []FrameHeader, error) {
+	var frames [][]byte
+	var headers []FrameHeader
+	for len(data) > 0 {
+		h, err := ParseFrameHeader(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("frame %d: %w", len(frames), err)
+		}
+		end := len(data)
+		c := crc.Update16(0, data[:h.Len])
+		scanned := h.Len
+		for i := h.Len; i+1 < len(data); i++ {
+			if data[i] != 0xff || data[i+1]&0xfe != 0xf8 {
+				continue
+			}
+			// the frame ends with checksum of 2 bytes
+			c = crc.Update16(c, data[scanned:i])
+			scanned = i
+			if c != 0 {
+				continue
+			}
+			if next, err := ParseFrameHeader(data[i:]); err == nil && next.Variable == h.Variable {
+				end = i
+				break
+			}
+		}
+		if end == len(data) && crc.Update16(c, data[scanned:]) != 0 {
+			return nil, nil, fmt.Errorf("frame %d: checksum mismatch", len(frames))
+		}
+		frames = append(frames, data[:end:end])
+		headers = append(headers, h)
+		data = data[end:]
+	}
+	return frames, headers, nil
+}
@@ -0,0 +1,124 @@
+package flac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/sr8e/vorbis/crc"
+)
+
+// frameHeader appends the checksum to the header fields.
+func frameHeader(fields ...byte) []byte {
+	return append(fields, crc.CRC8(fields))
+}
+
+// testFrame builds a frame of header and body, appending the checksum of the frame.
+func testFrame(header, body []byte) []byte {
+	b := append(bytes.Clone(header), body...)
+	return binary.BigEndian.AppendUint16(b, crc.Update16(0, b))
+}
+
+func TestParseFrameHeader(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		header []byte
+		want   FrameHeader
+	}{
+		{
+			"fixed",
+			// 4096 samples, 44100Hz, 2 channels, 16 bits, frame 0
+			frameHeader(0xff, 0xf8, 0xc9, 0x18, 0x00),
+			FrameHeader{BlockSize: 4096, SampleRate: 44100, Channels: 2, BitsPerSample: 16, Len: 6},
+		},
+		{
+			"variable",
+			// block size in 16 bits, sample rate in kHz, left/side, bits from STREAMINFO, sample 128
+			frameHeader(0xff, 0xf9, 0x7c, 0x80, 0xc2, 0x80, 0x03, 0xe7, 48),
+			FrameHeader{Variable: true, BlockSize: 1000, SampleRate: 48000, Channels: 2, Number: 128, Len: 10},
+		},
+		{
+			"7 bytes number",
+			// block size in 8 bits, sample rate in Hz, 1 channel, 24 bits
+			frameHeader(0xff, 0xf9, 0x6d, 0x0c, 0xfe, 0x83, 0x80, 0x80, 0x80, 0x80, 0x80, 0xbf, 0x56, 0x22),
+			FrameHeader{Variable: true, BlockSize: 192, SampleRate: 22050, Channels: 1, BitsPerSample: 24, Number: 3 << 30, Len: 15},
+		},
+	} {
+		got, err := ParseFrameHeader(tt.header)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+		// header cut anywhere is rejected
+		for n := 0; n < len(tt.header); n++ {
+			if _, err := ParseFrameHeader(tt.header[:n]); err == nil {
+				t.Errorf("%s: no error for header truncated to %d bytes", tt.name, n)
+			}
+		}
+	}
+}
+
+func TestParseFrameHeaderInvalid(t *testing.T) {
+	valid := frameHeader(0xff, 0xf8, 0xc9, 0x18, 0x00)
+	corrupted := bytes.Clone(valid)
+	corrupted[2] = 0xca // 48000Hz, with the checksum of 44100Hz
+	for _, tt := range []struct {
+		name   string
+		header []byte
+	}{
+		{"sync code", frameHeader(0xff, 0xf0, 0xc9, 0x18, 0x00)},
+		{"reserved block size", frameHeader(0xff, 0xf8, 0x09, 0x18, 0x00)},
+		{"reserved sample rate", frameHeader(0xff, 0xf8, 0xcf, 0x18, 0x00)},
+		{"reserved channels", frameHeader(0xff, 0xf8, 0xc9, 0xb8, 0x00)},
+		{"reserved sample size", frameHeader(0xff, 0xf8, 0xc9, 0x16, 0x00)},
+		{"reserved bit", frameHeader(0xff, 0xf8, 0xc9, 0x19, 0x00)},
+		{"checksum", corrupted},
+		{"number continuation", frameHeader(0xff, 0xf8, 0xc9, 0x18, 0x80)},
+		{"number without continuation", frameHeader(0xff, 0xf8, 0xc9, 0x18, 0xc2, 0x00)},
+		{"number exceeding 31 bits", frameHeader(0xff, 0xf8, 0xc9, 0x18, 0xfe, 0x83, 0x80, 0x80, 0x80, 0x80, 0x80)},
+	} {
+		if _, err := ParseFrameHeader(tt.header); err == nil {
+			t.Errorf("%s: got no error", tt.name)
+		}
+	}
+}
+
+func TestSplitFrames(t *testing.T) {
+	header := func(n byte) []byte { return frameHeader(0xff, 0xf8, 0xc9, 0x18, n) }
+	// a valid header inside the body does not end the frame, since the checksum does not match there
+	frames := [][]byte{
+		testFrame(header(0), append([]byte{1, 2, 3}, header(1)...)),
+		testFrame(header(1), []byte{0xff, 0xf8}),
+		testFrame(header(2), nil),
+	}
+	data := bytes.Join(frames, nil)
+	got, headers, err := splitFrames(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(frames) {
+		t.Fatalf("got %d frames, want %d", len(got), len(frames))
+	}
+	for i := range got {
+		if !bytes.Equal(got[i], frames[i]) || headers[i].Number != uint64(i) {
+			t.Errorf("frame %d: got %x, number %d, want %x", i, got[i], headers[i].Number, frames[i])
+		}
+	}
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"truncated", data[:len(data)-1]},
+		{"corrupted body", append(append(bytes.Clone(frames[0][:7]), 0), data[8:]...)},
+		{"garbage after frames", append(bytes.Clone(data), 1)},
+		{"corrupted header", append([]byte{0xff, 0xf8, 0xca}, data[3:]...)},
+	} {
+		if _, _, err := splitFrames(tt.data); err == nil {
+			t.Errorf("%s: got no error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Package flac moves FLAC streams between native files and Ogg, following the Ogg FLAC mapping,
+// without decoding audio.
+package flac
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrNotFLAC is returned when the stream is not FLAC.
+var ErrNotFLAC = errors.New("not a FLAC stream")
+
+// types of metadata blocks
+const (
+	BlockStreamInfo    = 0
+	BlockPadding       = 1
+	BlockApplication   = 2
+	BlockSeekTable     = 3
+	BlockVorbisComment = 4
+	BlockCueSheet      = 5
+	BlockPicture       = 6
+)
+
+// streamInfoLen is the length of STREAMINFO block, excluding its header.
+const streamInfoLen = 34
+
+// maxBlockLen is the maximum length of metadata block, in 24 bits.
+const maxBlockLen = 1<<24 - 1
+
+// MetadataBlock is a metadata block, whose header is rebuilt on writing.
+type MetadataBlock struct {
+	Type uint8
+	Data []byte
+}
+
+// StreamInfo is the content of STREAMINFO block.
+type StreamInfo struct {
+	MinBlockSize  uint16
+	MaxBlockSize  uint16
+	MinFrameSize  uint32 // 0 for unknown
+	MaxFrameSize  uint32 // 0 for unknown
+	SampleRate    uint32
+	Channels      uint8
+	BitsPerSample uint8
+	TotalSamples  uint64 // 0 for unknown
+	MD5           [16]byte
+}
+
+// ParseStreamInfo parses the content of STREAMINFO block.
+func ParseStreamInfo(data []byte) (StreamInfo, error) {
+	if len(data) != streamInfoLen {
+		return StreamInfo{}, fmt.Errorf("STREAMINFO of %d bytes", len(data))
+	}
+	// sample rate in 20 bits, channels - 1 in 3 bits, bits per sample - 1 in 5 bits, total samples in 36 bits
+	packed := binary.BigEndian.Uint64(data[10:18])
+	si := StreamInfo{
+		MinBlockSize:  binary.BigEndian.Uint16(data[0:2]),
+		MaxBlockSize:  binary.BigEndian.Uint16(data[2:4]),
+		MinFrameSize:  uint32(data[4])<<16 | uint32(data[5])<<8 | uint32(data[6]),
+		MaxFrameSize:  uint32(data[7])<<16 | uint32(data[8])<<8 | uint32(data[9]),
+		SampleRate:    uint32(packed >> 44),
+		Channels:      uint8(packed>>41&0x7) + 1,
+		BitsPerSample: uint8(packed>>36&0x1f) + 1,
+		TotalSamples:  packed & (1<<36 - 1),
+	}
+	copy(si.MD5[:], data[18:])
+	if si.MaxBlockSize < si.MinBlockSize {
+		return StreamInfo{}, fmt.Errorf("invalid block sizes %d to %d", si.MinBlockSize, si.MaxBlockSize)
+	}
+	if si.SampleRate == 0 {
+		return StreamInfo{}, errors.New("sample rate is zero")
+	}
+	return si, nil
+}
+
+// readBlockHeader reads the header of metadata block, and returns its type, length of content and last-block flag.
+func readBlockHeader(b []byte) (typ uint8, length int, last bool, err error) {
+	if len(b) < 4 {
+		return 0, 0, false, errors.New("metadata block header is truncated")
+	}
+	typ = b[0] & 0x7f
+	if typ == 127 {
+		return 0, 0, false, errors.New("invalid metadata block type")
+	}
+	length = int(b[1])<<16 | int(b[2])<<8 | int(b[3])
+	return typ, length, b[0]&0x80 != 0, nil
+}
+
+// appendBlock appends the metadata block with its header to b.
+func appendBlock(b []byte, m MetadataBlock, last bool) ([]byte, error) {
+	if len(m.Data) > maxBlockLen {
+		return nil, fmt.Errorf("metadata block of %d bytes is too large", len(m.Data))
+	}
+	h := m.Type & 0x7f
+	if last {
+		h |= 0x80
+	}
+	n := len(m.Data)
+	b = append(b, h, byte(n>>16), byte(n>>8), byte(n))
+	return append(b, m.Data...), nil
+}
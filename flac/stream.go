@@ -0,0 +1,261 @@
+package flac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+// Stream is a FLAC stream split into metadata blocks and audio frames.
+type Stream struct {
+	StreamInfo StreamInfo
+	// Metadata is metadata blocks in order, beginning with STREAMINFO.
+	Metadata []MetadataBlock
+	Frames   [][]byte
+	// BlockSizes is the number of samples in each frame.
+	BlockSizes []int
+}
+
+// newStream validates the metadata blocks, and parses STREAMINFO.
+func newStream(blocks []MetadataBlock) (*Stream, error) {
+	if len(blocks) == 0 || blocks[0].Type != BlockStreamInfo {
+		return nil, fmt.Errorf("%w: STREAMINFO is not the first metadata block", ErrNotFLAC)
+	}
+	si, err := ParseStreamInfo(blocks[0].Data)
+	if err != nil {
+		return nil, err
+	}
+	return &Stream{StreamInfo: si, Metadata: blocks}, nil
+}
+
+// addFrame appends a frame after validating its header.
+func (s *Stream) addFrame(data []byte, h FrameHeader) error {
+	if h.Channels != s.StreamInfo.Channels {
+		return fmt.Errorf("frame of %d channels in stream of %d", h.Channels, s.StreamInfo.Channels)
+	}
+	s.Frames = append(s.Frames, data)
+	s.BlockSizes = append(s.BlockSizes, h.BlockSize)
+	return nil
+}
+
+// ReadNative reads a native FLAC file, splitting frames by their headers and checksums.
+func ReadNative(r io.Reader) (*Stream, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte("fLaC")) {
+		return nil, fmt.Errorf("%w: signature not found", ErrNotFLAC)
+	}
+	data = data[4:]
+
+	var blocks []MetadataBlock
+	for last := false; !last; {
+		var typ uint8
+		var n int
+		typ, n, last, err = readBlockHeader(data)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) < 4+n {
+			return nil, fmt.Errorf("metadata block %d is truncated", len(blocks))
+		}
+		blocks = append(blocks, MetadataBlock{Type: typ, Data: data[4 : 4+n : 4+n]})
+		data = data[4+n:]
+	}
+	s, err := newStream(blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	frames, headers, err := splitFrames(data)
+	if err != nil {
+		return nil, err
+	}
+	for i := range frames {
+		if err := s.addFrame(frames[i], headers[i]); err != nil {
+			return nil, fmt.Errorf("frame %d: %w", i, err)
+		}
+	}
+	return s, nil
+}
+
+// WriteNative writes the stream as a native FLAC file. The last-block flag is set on the last metadata block.
+func (s *Stream) WriteNative(w io.Writer) error {
+	b := []byte("fLaC")
+	var err error
+	for i, m := range s.Metadata {
+		b, err = appendBlock(b, m, i == len(s.Metadata)-1)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	for _, f := range s.Frames {
+		if _, err := w.Write(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// oggSignature begins the first header packet of Ogg FLAC, followed by mapping version.
+const oggSignature = "\x7fFLAC"
+
+// ReadOgg reads Ogg bitstream from r, and returns the first FLAC logical stream in it.
+// Each header packet holds a metadata block, and each audio packet holds a frame.
+func ReadOgg(r io.Reader) (*Stream, error) {
+	var ol ogg.OggLoader
+	err := ol.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	err = ol.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	serials := make([]uint32, 0, len(ol.Streams))
+	for serial := range ol.Streams {
+		serials = append(serials, serial)
+	}
+	slices.Sort(serials)
+
+	err = ErrNotFLAC
+	for _, serial := range serials {
+		s := ol.Streams[serial]
+		packets, streamErr := s.GetPackets()
+		if streamErr != nil {
+			err = streamErr
+			continue
+		}
+		if len(packets) == 0 || !bytes.HasPrefix(packets[0].Bytes(), []byte(oggSignature)) {
+			continue
+		}
+		return readOggPackets(packets)
+	}
+	return nil, err
+}
+
+func readOggPackets(packets []ogg.Packet) (*Stream, error) {
+	// signature, version 1.0, number of header packets, native signature, STREAMINFO block
+	first := packets[0].Bytes()
+	if len(first) != 13+4+streamInfoLen || string(first[9:13]) != "fLaC" {
+		return nil, fmt.Errorf("%w: invalid mapping header", ErrNotFLAC)
+	}
+	if first[5] != 1 {
+		return nil, fmt.Errorf("incompatible mapping version %d.%d", first[5], first[6])
+	}
+	typ, n, _, err := readBlockHeader(first[13:])
+	if err != nil {
+		return nil, err
+	}
+	if typ != BlockStreamInfo || n != streamInfoLen {
+		return nil, fmt.Errorf("%w: invalid STREAMINFO block", ErrNotFLAC)
+	}
+	blocks := []MetadataBlock{{Type: BlockStreamInfo, Data: first[17:]}}
+
+	// 0 for unknown number of header packets, which continue until the last-block flag
+	count := int(binary.BigEndian.Uint16(first[7:9]))
+	packets = packets[1:]
+	for i := 0; count == 0 || i < count; i++ {
+		if len(packets) == 0 {
+			return nil, errors.New("header packets are missing")
+		}
+		p := packets[0].Bytes()
+		typ, n, last, err := readBlockHeader(p)
+		if err != nil {
+			return nil, err
+		}
+		if n != len(p)-4 {
+			return nil, fmt.Errorf("metadata block of %d bytes in packet of %d", n, len(p))
+		}
+		blocks = append(blocks, MetadataBlock{Type: typ, Data: p[4:]})
+		packets = packets[1:]
+		if count == 0 && last {
+			break
+		}
+	}
+	s, err := newStream(blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range packets {
+		p := packets[i].Bytes()
+		h, err := ParseFrameHeader(p)
+		if err != nil {
+			return nil, fmt.Errorf("packet %d: %w", packets[i].Index(), err)
+		}
+		if err := s.addFrame(p, h); err != nil {
+			return nil, fmt.Errorf("packet %d: %w", packets[i].Index(), err)
+		}
+	}
+	return s, nil
+}
+
+// WriteOgg writes the stream to w as an Ogg logical stream of serial.
+// The VORBIS_COMMENT block, which the mapping requires right after STREAMINFO, is moved there or added empty.
+// The granule position of a page is the number of samples through the last frame ending on it.
+func (s *Stream) WriteOgg(w io.Writer, serial uint32) error {
+	if len(s.Metadata) == 0 || s.Metadata[0].Type != BlockStreamInfo {
+		return errors.New("STREAMINFO is not the first metadata block")
+	}
+	blocks := s.Metadata[1:]
+	i := slices.IndexFunc(blocks, func(m MetadataBlock) bool { return m.Type == BlockVorbisComment })
+	if i < 0 {
+		// vendor string and number of comments, both empty
+		comment := MetadataBlock{Type: BlockVorbisComment, Data: make([]byte, 8)}
+		blocks = append([]MetadataBlock{comment}, blocks...)
+	} else {
+		blocks = append(append([]MetadataBlock{blocks[i]}, blocks[:i]...), blocks[i+1:]...)
+	}
+	if len(blocks) > 0xffff {
+		return fmt.Errorf("too many metadata blocks: %d", len(blocks))
+	}
+
+	first := []byte(oggSignature + "\x01\x00")
+	first = binary.BigEndian.AppendUint16(first, uint16(len(blocks)))
+	first = append(first, "fLaC"...)
+	first, err := appendBlock(first, s.Metadata[0], false)
+	if err != nil {
+		return err
+	}
+
+	pw := ogg.NewPacketWriter(w, serial)
+	// the first header packet takes the first page alone, and audio begins on a new page
+	if err := pw.WritePacket(first, 0); err != nil {
+		return err
+	}
+	if err := pw.Flush(); err != nil {
+		return err
+	}
+	for i, m := range blocks {
+		b, err := appendBlock(nil, m, i == len(blocks)-1)
+		if err != nil {
+			return err
+		}
+		if err := pw.WritePacket(b, 0); err != nil {
+			return err
+		}
+	}
+	if err := pw.Flush(); err != nil {
+		return err
+	}
+
+	var granule uint64
+	for i, f := range s.Frames {
+		granule += uint64(s.BlockSizes[i])
+		if err := pw.WritePacket(f, granule); err != nil {
+			return err
+		}
+	}
+	return pw.Close()
+}
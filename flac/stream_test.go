@@ -0,0 +1,266 @@
+package flac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+// testStreamInfo encodes STREAMINFO of 2 channels, 16 bits at 44100Hz, with block size of 4096.
+func testStreamInfo(total uint64) MetadataBlock {
+	b := binary.BigEndian.AppendUint16(nil, 4096)
+	b = binary.BigEndian.AppendUint16(b, 4096)
+	b = append(b, make([]byte, 6)...) // frame sizes unknown
+	b = binary.BigEndian.AppendUint64(b, 44100<<44|1<<41|15<<36|total)
+	b = append(b, make([]byte, 16)...)
+	return MetadataBlock{Type: BlockStreamInfo, Data: b}
+}
+
+// testNative builds a native FLAC file of metadata blocks following STREAMINFO,
+// and 4 frames of 4096 samples and the last of 1000, each of which fills a page.
+func testNative(metadata ...MetadataBlock) (data []byte, frames [][]byte) {
+	for i := 0; i < 5; i++ {
+		h := frameHeader(0xff, 0xf8, 0xc9, 0x18, byte(i))
+		if i == 4 {
+			// block size in 16 bits
+			h = frameHeader(0xff, 0xf8, 0x79, 0x18, byte(i), 0x03, 0xe7)
+		}
+		frames = append(frames, testFrame(h, bytes.Repeat([]byte{byte(i + 1)}, 5000)))
+	}
+	blocks := append([]MetadataBlock{testStreamInfo(4*4096 + 1000)}, metadata...)
+	data = []byte("fLaC")
+	for i, m := range blocks {
+		data, _ = appendBlock(data, m, i == len(blocks)-1)
+	}
+	return append(data, bytes.Join(frames, nil)...), frames
+}
+
+var (
+	testComment     = MetadataBlock{Type: BlockVorbisComment, Data: []byte("\x03\x00\x00\x00abc\x00\x00\x00\x00")}
+	testPadding     = MetadataBlock{Type: BlockPadding, Data: make([]byte, 10)}
+	testApplication = MetadataBlock{Type: BlockApplication, Data: []byte("TESTdata")}
+	// added by WriteOgg if missing
+	emptyComment = MetadataBlock{Type: BlockVorbisComment, Data: make([]byte, 8)}
+)
+
+func TestReadNative(t *testing.T) {
+	data, frames := testNative(testComment, testPadding)
+	s, err := ReadNative(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := StreamInfo{MinBlockSize: 4096, MaxBlockSize: 4096, SampleRate: 44100, Channels: 2, BitsPerSample: 16, TotalSamples: 4*4096 + 1000}
+	if s.StreamInfo != want {
+		t.Errorf("got %+v, want %+v", s.StreamInfo, want)
+	}
+	if len(s.Metadata) != 3 || s.Metadata[1].Type != BlockVorbisComment || s.Metadata[2].Type != BlockPadding {
+		t.Errorf("got %d metadata blocks", len(s.Metadata))
+	}
+	if len(s.Frames) != len(frames) {
+		t.Fatalf("got %d frames, want %d", len(s.Frames), len(frames))
+	}
+	for i := range frames {
+		if !bytes.Equal(s.Frames[i], frames[i]) {
+			t.Errorf("frame %d differs", i)
+		}
+	}
+	wantSizes := []int{4096, 4096, 4096, 4096, 1000}
+	for i, size := range s.BlockSizes {
+		if size != wantSizes[i] {
+			t.Errorf("frame %d: got block size %d, want %d", i, size, wantSizes[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := s.WriteNative(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("written file differs from the source")
+	}
+}
+
+func TestReadNativeInvalid(t *testing.T) {
+	data, frames := testNative(testComment)
+	last := len(data) - len(frames[4])
+	// frame of 1 channel, in a stream of 2
+	mono := append(bytes.Clone(data[:last]), testFrame(frameHeader(0xff, 0xf8, 0x79, 0x08, 4, 0x03, 0xe7), nil)...)
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"signature", append([]byte("fLaX"), data[4:]...)},
+		{"truncated metadata", data[:4+4+34+4+5]},
+		{"not STREAMINFO first", append([]byte("fLaC"), 0x84, 0, 0, 0)},
+		{"truncated frame", data[:len(data)-1]},
+		{"corrupted frame header", append(append(bytes.Clone(data[:last]), 0x00), data[last+1:]...)},
+		{"channels", mono},
+	} {
+		if _, err := ReadNative(bytes.NewReader(tt.data)); err == nil {
+			t.Errorf("%s: got no error", tt.name)
+		}
+	}
+}
+
+// readTestOgg reads packets of the single stream in Ogg.
+func readTestOgg(t *testing.T, data []byte) []ogg.Packet {
+	t.Helper()
+	var ol ogg.OggLoader
+	if err := ol.OpenReader(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ol.ReadAll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ol.Streams) != 1 {
+		t.Fatalf("got %d streams, want 1", len(ol.Streams))
+	}
+	for _, s := range ol.Streams {
+		packets, err := s.GetPackets()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return packets
+	}
+	return nil
+}
+
+func TestOggRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata []MetadataBlock
+		// metadata blocks in Ogg following STREAMINFO, in which native file is restored
+		want []MetadataBlock
+	}{
+		{"comment first", []MetadataBlock{testComment, testPadding}, []MetadataBlock{testComment, testPadding}},
+		{"comment moved", []MetadataBlock{testApplication, testPadding, testComment}, []MetadataBlock{testComment, testApplication, testPadding}},
+		{"comment added", []MetadataBlock{testPadding}, []MetadataBlock{emptyComment, testPadding}},
+		{"STREAMINFO only", nil, []MetadataBlock{emptyComment}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, frames := testNative(tt.metadata...)
+			s, err := ReadNative(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var oggBuf bytes.Buffer
+			if err := s.WriteOgg(&oggBuf, 1); err != nil {
+				t.Fatal(err)
+			}
+
+			packets := readTestOgg(t, oggBuf.Bytes())
+			if len(packets) != 1+len(tt.want)+len(frames) {
+				t.Fatalf("got %d packets, want %d", len(packets), 1+len(tt.want)+len(frames))
+			}
+			first := packets[0].Bytes()
+			if !bytes.HasPrefix(first, []byte(oggSignature+"\x01\x00")) || int(binary.BigEndian.Uint16(first[7:9])) != len(tt.want) {
+				t.Errorf("invalid first packet %x", first[:13])
+			}
+			for i, m := range tt.want {
+				if want, _ := appendBlock(nil, m, i == len(tt.want)-1); !bytes.Equal(packets[1+i].Bytes(), want) {
+					t.Errorf("header packet %d: got %x, want %x", i+1, packets[1+i].Bytes(), want)
+				}
+			}
+			// audio begins on a new page, and each page ends at the number of samples through its last frame
+			headers := packets[:1+len(tt.want)]
+			if g, ok := headers[len(headers)-1].Granule(); !ok || g != 0 {
+				t.Errorf("got granule %d, %t for the last header packet, want 0", g, ok)
+			}
+			var total uint64
+			for i, p := range packets[len(headers):] {
+				total += uint64(s.BlockSizes[i])
+				if !bytes.Equal(p.Bytes(), frames[i]) {
+					t.Errorf("frame %d differs", i)
+				}
+				if g, ok := p.Granule(); !ok || g != total {
+					t.Errorf("frame %d: got granule %d, %t, want %d", i, g, ok, total)
+				}
+			}
+
+			restored, err := ReadOgg(bytes.NewReader(oggBuf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var nativeBuf bytes.Buffer
+			if err := restored.WriteNative(&nativeBuf); err != nil {
+				t.Fatal(err)
+			}
+			if want, _ := testNative(tt.want...); !bytes.Equal(nativeBuf.Bytes(), want) {
+				t.Error("restored native file differs")
+			}
+			if restored.StreamInfo != s.StreamInfo {
+				t.Errorf("got %+v, want %+v", restored.StreamInfo, s.StreamInfo)
+			}
+
+			// metadata read in the order of Ogg is written back as is
+			var again bytes.Buffer
+			if err := restored.WriteOgg(&again, 1); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(again.Bytes(), oggBuf.Bytes()) {
+				t.Error("Ogg written again differs")
+			}
+		})
+	}
+}
+
+func TestReadOggNotFLAC(t *testing.T) {
+	data, err := os.ReadFile("../testdata/test.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadOgg(bytes.NewReader(data)); !errors.Is(err, ErrNotFLAC) {
+		t.Errorf("got %v, want ErrNotFLAC", err)
+	}
+}
+
+func TestReadOggInvalid(t *testing.T) {
+	data, _ := testNative(testComment)
+	s, err := ReadNative(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := s.WriteOgg(&buf, 1); err != nil {
+		t.Fatal(err)
+	}
+	packets := readTestOgg(t, buf.Bytes())
+
+	// rewrite packets into Ogg after modify changes them
+	rewrite := func(modify func([][]byte) [][]byte) []byte {
+		var b [][]byte
+		for i := range packets {
+			b = append(b, bytes.Clone(packets[i].Bytes()))
+		}
+		var out bytes.Buffer
+		pw := ogg.NewPacketWriter(&out, 1)
+		for _, p := range modify(b) {
+			if err := pw.WritePacket(p, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := pw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return out.Bytes()
+	}
+	for _, tt := range []struct {
+		name   string
+		modify func([][]byte) [][]byte
+	}{
+		{"mapping version", func(b [][]byte) [][]byte { b[0][5] = 2; return b }},
+		{"first packet length", func(b [][]byte) [][]byte { b[0] = b[0][:len(b[0])-1]; return b }},
+		{"header packets missing", func(b [][]byte) [][]byte { b[0][8] = 3; return b }},
+		{"block length", func(b [][]byte) [][]byte { b[1][3]++; return b }},
+		{"corrupted frame header", func(b [][]byte) [][]byte { b[2][2] = 0xca; return b }},
+	} {
+		if _, err := ReadOgg(bytes.NewReader(rewrite(tt.modify))); err == nil {
+			t.Errorf("%s: got no error", tt.name)
+		}
+	}
+}
@@ -75,6 +75,10 @@ func (n *Node) iterateStructure() string {
 }
 
 func GenerateHuffmanTree(cwLen []int) (_ HuffmanTree, err error) {
+	if len(cwLen) == 0 || slices.Max(cwLen) < 1 {
+		err = errors.New("no codeword is used")
+		return
+	}
 
 	root := &Node{index: -1}
 	cwMaxLen := slices.Max(cwLen)
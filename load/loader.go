@@ -7,35 +7,127 @@ import (
 	"os"
 )
 
+const bufSize = 4096
+
+var ErrNotSeekable = errors.New("source is not seekable")
+
 type BinaryLoader struct {
-	file   *os.File
+	mapped []byte // non-nil if the source is memory-mapped
+	src    io.Reader
+	seeker io.Seeker // nil if the source is forward-only
+	closer io.Closer // non-nil if the loader owns the source
 	buf    []byte
-	cur    int // cursor position of buf going to be read.
-	bufLen int // length of buffer.  bufLen - cur bytes can be read.
+	cur    int   // cursor position of buf going to be read.
+	bufLen int   // length of buffer.  bufLen - cur bytes can be read.
+	pos    int64 // offset in source at the end of buffered data.
 }
 
+// Open opens the file at path as the source.
 func (bl *BinaryLoader) Open(path string) error {
-	if bl.file != nil {
+	if bl.isOpened() {
 		return errors.New("file is already opened")
 	}
 	fp, err := os.Open(path)
 	if err != nil {
 		return err
 	}
-	bl.file = fp
-	bl.buf = make([]byte, 4096)
+	bl.init(fp, fp, 0)
+	bl.closer = fp
 
 	return nil
 }
 
+// OpenReader sets forward-only source. Seek is not available.
+func (bl *BinaryLoader) OpenReader(r io.Reader) error {
+	if bl.isOpened() {
+		return errors.New("file is already opened")
+	}
+	bl.init(r, nil, 0)
+	return nil
+}
+
+// OpenReadSeeker sets random access source. Reading starts at the current offset of r.
+func (bl *BinaryLoader) OpenReadSeeker(r io.ReadSeeker) error {
+	if bl.isOpened() {
+		return errors.New("file is already opened")
+	}
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	bl.init(r, r, pos)
+	return nil
+}
+
+// OpenReaderAt sets random access source of size bytes. Reading starts at offset 0.
+func (bl *BinaryLoader) OpenReaderAt(r io.ReaderAt, size int64) error {
+	return bl.OpenReadSeeker(io.NewSectionReader(r, 0, size))
+}
+
+func (bl *BinaryLoader) isOpened() bool {
+	return bl.src != nil || bl.mapped != nil
+}
+
+func (bl *BinaryLoader) init(r io.Reader, s io.Seeker, pos int64) {
+	bl.src = r
+	bl.seeker = s
+	bl.buf = make([]byte, bufSize)
+	bl.cur = 0
+	bl.bufLen = 0
+	bl.pos = pos
+}
+
+// Close closes the source if it is opened by Open. Sources given by caller are left open.
 func (bl *BinaryLoader) Close() error {
-	return bl.file.Close()
+	if bl.mapped != nil {
+		err := unmap(bl.mapped)
+		bl.mapped = nil
+		return err
+	}
+	if bl.closer == nil {
+		return nil
+	}
+	return bl.closer.Close()
+}
+
+// Tell returns the offset in source of the byte going to be read next.
+func (bl *BinaryLoader) Tell() int64 {
+	return bl.pos - int64(bl.bufLen-bl.cur)
 }
 
+// Seek sets the offset of the byte going to be read next, interpreted according to whence as io.Seeker.
+func (bl *BinaryLoader) Seek(offset int64, whence int) (int64, error) {
+	if bl.mapped != nil {
+		return bl.seekMapped(offset, whence)
+	}
+	if bl.seeker == nil {
+		return 0, ErrNotSeekable
+	}
+	if whence == io.SeekCurrent {
+		offset += bl.Tell()
+		whence = io.SeekStart
+	}
+	pos, err := bl.seeker.Seek(offset, whence)
+	if err != nil {
+		return 0, err
+	}
+	// discard buffered data
+	bl.cur = 0
+	bl.bufLen = 0
+	bl.pos = pos
+	return pos, nil
+}
+
+// GetBytes reads n bytes from the source.
+// On error, it returns the bytes read so far along with the error.
+// For memory-mapped source, returned slice shares memory with the mapping and must not be modified.
 func (bl *BinaryLoader) GetBytes(n int) ([]byte, error) {
 	if n == 0 {
 		return []byte{}, nil
 	}
+	if bl.mapped != nil {
+		return bl.getMappedBytes(n)
+	}
 	if n <= bl.bufLen-bl.cur {
 		b := make([]byte, 0, n)
 		b = append(b, bl.buf[bl.cur:bl.cur+n]...)
@@ -47,20 +139,25 @@ func (bl *BinaryLoader) GetBytes(n int) ([]byte, error) {
 	b := make([]byte, 0, n)
 	b = append(b, bl.buf[bl.cur:bl.bufLen]...)
 	bl.cur = 0
+	bl.bufLen = 0
 
 	for resLen > 0 {
-		readSize, err := bl.file.Read(bl.buf)
+		readSize, err := bl.src.Read(bl.buf)
 		bl.bufLen = readSize
-		if err != nil {
-			if readSize == 0 && errors.Is(err, io.EOF) {
-				return nil, fmt.Errorf("encountered EOF while reading: %w", err)
-			}
-			return nil, err
-		}
+		bl.pos += int64(readSize)
+
 		size := min(readSize, resLen)
 		b = append(b, bl.buf[0:size]...)
 		resLen -= size
 		bl.cur = size
+
+		// some readers return error along with the last data
+		if err != nil && resLen > 0 {
+			if errors.Is(err, io.EOF) {
+				return b, fmt.Errorf("encountered EOF while reading: %w", err)
+			}
+			return b, err
+		}
 	}
 	return b, nil
 }
@@ -0,0 +1,72 @@
+package load
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// OpenMmap maps the file at path into memory as the source.
+// Bytes returned by GetBytes are sub-slices of the mapping, which are valid until Close.
+func (bl *BinaryLoader) OpenMmap(path string) error {
+	if bl.isOpened() {
+		return errors.New("file is already opened")
+	}
+	fp, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	info, err := fp.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		// nothing to map. treat as empty source
+		bl.mapped = []byte{}
+		bl.pos = 0
+		return nil
+	}
+	data, err := mmap(fp, int(info.Size()))
+	if err != nil {
+		return err
+	}
+	bl.mapped = data
+	bl.pos = 0
+	return nil
+}
+
+// Mapped reports whether the source is memory-mapped, whose size is fixed at OpenMmap.
+func (bl *BinaryLoader) Mapped() bool {
+	return bl.mapped != nil
+}
+
+func (bl *BinaryLoader) getMappedBytes(n int) ([]byte, error) {
+	size := int64(len(bl.mapped))
+	// the position may be past the end after seeking
+	start := min(bl.pos, size)
+	if int64(n) > size-start {
+		bl.pos = max(bl.pos, size)
+		return bl.mapped[start:size:size], fmt.Errorf("encountered EOF while reading: %w", io.EOF)
+	}
+	bl.pos = start + int64(n)
+	// limit capacity so that appending to it never writes into the mapping
+	return bl.mapped[start:bl.pos:bl.pos], nil
+}
+
+func (bl *BinaryLoader) seekMapped(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += bl.pos
+	case io.SeekEnd:
+		offset += int64(len(bl.mapped))
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	// seeking past the end is allowed as files, and reading there returns io.EOF
+	bl.pos = offset
+	return offset, nil
+}
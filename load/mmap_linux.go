@@ -0,0 +1,19 @@
+//go:build linux
+
+package load
+
+import (
+	"os"
+	"syscall"
+)
+
+func mmap(fp *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(fp.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func unmap(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package load
+
+import (
+	"errors"
+	"os"
+)
+
+func mmap(_ *os.File, _ int) ([]byte, error) {
+	return nil, errors.New("mmap is not supported on this platform")
+}
+
+func unmap(_ []byte) error {
+	return nil
+}
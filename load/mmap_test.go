@@ -0,0 +1,110 @@
+package load
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestFile writes size bytes of a pattern into a temporary file.
+func writeTestFile(t *testing.T, size int) (string, []byte) {
+	t.Helper()
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+func openMmap(t *testing.T, path string) *BinaryLoader {
+	t.Helper()
+	var bl BinaryLoader
+	if err := bl.OpenMmap(path); err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { bl.Close() })
+	return &bl
+}
+
+func TestMmapGetBytes(t *testing.T) {
+	path, data := writeTestFile(t, 10000)
+	var file BinaryLoader
+	if err := file.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	mapped := openMmap(t, path)
+	if !mapped.Mapped() || file.Mapped() {
+		t.Fatal("Mapped reports the wrong source")
+	}
+
+	for _, n := range []int{0, 1, 27, 4096, 5000, 1000} {
+		want, wantErr := file.GetBytes(n)
+		got, err := mapped.GetBytes(n)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%d bytes from %d differ", n, file.Tell())
+		}
+		if errors.Is(err, io.EOF) != errors.Is(wantErr, io.EOF) {
+			t.Fatalf("got error %v, want %v", err, wantErr)
+		}
+		if mapped.Tell() != file.Tell() {
+			t.Fatalf("got position %d, want %d", mapped.Tell(), file.Tell())
+		}
+	}
+
+	// appending to returned bytes never writes into the mapping
+	if _, err := mapped.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	b, err := mapped.GetBytes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = append(b, 0xff)
+	if next, _ := mapped.GetBytes(1); next[0] != data[10] {
+		t.Error("appending to returned bytes modified the mapping")
+	}
+}
+
+func TestMmapSeekPastEOF(t *testing.T) {
+	path, data := writeTestFile(t, 100)
+	bl := openMmap(t, path)
+
+	pos, err := bl.Seek(110, io.SeekStart)
+	if err != nil || pos != 110 {
+		t.Fatalf("got position %d and error %v, want 110", pos, err)
+	}
+	b, err := bl.GetBytes(4)
+	if len(b) != 0 || !errors.Is(err, io.EOF) {
+		t.Errorf("got %d bytes and error %v past the end, want io.EOF", len(b), err)
+	}
+	if bl.Tell() != 110 {
+		t.Errorf("got position %d after reading past the end, want 110", bl.Tell())
+	}
+
+	if _, err := bl.Seek(-3, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	b, err = bl.GetBytes(5)
+	if !bytes.Equal(b, data[97:]) || !errors.Is(err, io.EOF) {
+		t.Errorf("got %v and error %v at the end, want %v and io.EOF", b, err, data[97:])
+	}
+	if _, err := bl.Seek(-200, io.SeekCurrent); err == nil {
+		t.Error("seeking to negative position succeeded")
+	}
+}
+
+func TestMmapEmpty(t *testing.T) {
+	path, _ := writeTestFile(t, 0)
+	bl := openMmap(t, path)
+	if b, err := bl.GetBytes(1); len(b) != 0 || !errors.Is(err, io.EOF) {
+		t.Errorf("got %d bytes and error %v from empty file, want io.EOF", len(b), err)
+	}
+}
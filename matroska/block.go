@@ -0,0 +1,117 @@
+package matroska
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+// lacing of frames in a block, from the flags
+const (
+	lacingNone  = 0
+	lacingXiph  = 1
+	lacingFixed = 2
+	lacingEBML  = 3
+)
+
+// parseBlock splits data of SimpleBlock or Block into frames.
+// keyframe is the flag of SimpleBlock, and always false for Block.
+func (ml *MatroskaLoader) parseBlock(data []byte, simple bool) (_ []Frame, keyframe bool, err error) {
+	track, n, _, err := parseVint(data)
+	if err != nil {
+		return
+	}
+	data = data[n:]
+	if len(data) < 3 {
+		err = errors.New("block header is truncated")
+		return
+	}
+	rel := int64(int16(uint16(data[0])<<8 | uint16(data[1])))
+	flags := data[2]
+	data = data[3:]
+	keyframe = simple && flags&0x80 != 0
+
+	var payloads [][]byte
+	switch (flags >> 1) & 3 {
+	case lacingNone:
+		payloads = [][]byte{data}
+	case lacingXiph:
+		payloads, err = ogg.XiphUnlace(data)
+	case lacingFixed:
+		payloads, err = unlaceFixed(data)
+	case lacingEBML:
+		payloads, err = unlaceEBML(data)
+	}
+	if err != nil {
+		err = fmt.Errorf("block of track %d: %w", track, err)
+		return
+	}
+
+	ts := time.Duration((ml.clusterTime + rel) * int64(ml.TimecodeScale))
+	frames := make([]Frame, len(payloads))
+	for i, p := range payloads {
+		frames[i] = Frame{Track: track, Timestamp: ts, Data: p}
+	}
+	return frames, keyframe, nil
+}
+
+// unlaceFixed splits data into frames of the same size.
+func unlaceFixed(data []byte) ([][]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty laced data")
+	}
+	n := int(data[0]) + 1
+	data = data[1:]
+	if len(data)%n != 0 {
+		return nil, fmt.Errorf("%d bytes cannot be split into %d frames", len(data), n)
+	}
+	size := len(data) / n
+	frames := make([][]byte, n)
+	for i := range frames {
+		frames[i] = data[i*size : (i+1)*size : (i+1)*size]
+	}
+	return frames, nil
+}
+
+// unlaceEBML splits data in EBML lacing: the size of the first frame in variable size integer,
+// followed by differences from the previous size in signed variable size integer, except for the last frame.
+func unlaceEBML(data []byte) ([][]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty laced data")
+	}
+	n := int(data[0]) + 1
+	data = data[1:]
+	sizes := make([]int64, n)
+	total := int64(0)
+	for i := 0; i < n-1; i++ {
+		v, l, _, err := parseVint(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[l:]
+		if i == 0 {
+			sizes[i] = int64(v)
+		} else {
+			// signed value is biased by half the range
+			sizes[i] = sizes[i-1] + int64(v) - (1<<(7*l-1) - 1)
+		}
+		if sizes[i] < 0 || sizes[i] > int64(len(data)) {
+			return nil, fmt.Errorf("invalid frame size %d", sizes[i])
+		}
+		total += sizes[i]
+	}
+	if total > int64(len(data)) {
+		return nil, errors.New("laced frames exceed data")
+	}
+	sizes[n-1] = int64(len(data)) - total
+
+	frames := make([][]byte, n)
+	pos := int64(0)
+	for i, size := range sizes {
+		frames[i] = data[pos : pos+size : pos+size]
+		pos += size
+	}
+	return frames, nil
+}
@@ -0,0 +1,151 @@
+package matroska
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+)
+
+// element IDs, with the length marker kept as in the spec
+const (
+	idEBML    = 0x1A45DFA3
+	idDocType = 0x4282
+	idVoid    = 0xEC
+	idCRC32   = 0xBF
+
+	idSegment       = 0x18538067
+	idSeekHead      = 0x114D9B74
+	idInfo          = 0x1549A966
+	idTimecodeScale = 0x2AD7B1
+	idTracks        = 0x1654AE6B
+	idCues          = 0x1C53BB6B
+	idTags          = 0x1254C367
+	idChapters      = 0x1043A770
+	idAttachments   = 0x1941A469
+
+	idTrackEntry        = 0xAE
+	idTrackNumber       = 0xD7
+	idTrackType         = 0x83
+	idCodecID           = 0x86
+	idCodecPrivate      = 0x63A2
+	idCodecDelay        = 0x56AA
+	idAudio             = 0xE1
+	idSamplingFrequency = 0xB5
+	idChannels          = 0x9F
+
+	idCluster        = 0x1F43B675
+	idTimecode       = 0xE7
+	idSimpleBlock    = 0xA3
+	idBlockGroup     = 0xA0
+	idBlock          = 0xA1
+	idReferenceBlock = 0xFB
+	idDiscardPadding = 0x75A2
+)
+
+// unknownSize is the size of element whose end is not specified, such as clusters of live streams.
+const unknownSize = -1
+
+// isTopLevel reports whether id is of an element at the level of children of segment,
+// which ends a cluster of unknown size.
+func isTopLevel(id uint32) bool {
+	switch id {
+	case idCluster, idSeekHead, idInfo, idTracks, idCues, idTags, idChapters, idAttachments, idSegment, idEBML:
+		return true
+	}
+	return false
+}
+
+// vintLen returns the length of variable size integer from its first byte, or 0 if invalid.
+func vintLen(b byte) int {
+	return bits.LeadingZeros8(b) + 1
+}
+
+// parseVint decodes variable size integer at the beginning of b, without the length marker.
+// It returns the value, its length, and whether all value bits are set, which means unknown.
+func parseVint(b []byte) (v uint64, n int, allOnes bool, err error) {
+	if len(b) == 0 {
+		return 0, 0, false, errors.New("empty variable size integer")
+	}
+	n = vintLen(b[0])
+	if n > 8 {
+		return 0, 0, false, errors.New("invalid variable size integer")
+	}
+	if len(b) < n {
+		return 0, 0, false, errors.New("variable size integer is truncated")
+	}
+	v = uint64(b[0]) & (0xff >> n)
+	for _, c := range b[1:n] {
+		v = v<<8 | uint64(c)
+	}
+	return v, n, v == 1<<(7*n)-1, nil
+}
+
+func readUint(b []byte) (uint64, error) {
+	if len(b) > 8 {
+		return 0, fmt.Errorf("unsigned integer of %d bytes", len(b))
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func readInt(b []byte) (int64, error) {
+	v, err := readUint(b)
+	if err != nil || len(b) == 0 {
+		return 0, err
+	}
+	// sign extension
+	shift := 64 - 8*len(b)
+	return int64(v<<shift) >> shift, nil
+}
+
+func readFloat(b []byte) (float64, error) {
+	switch len(b) {
+	case 0:
+		return 0, nil
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	}
+	return 0, fmt.Errorf("float of %d bytes", len(b))
+}
+
+func readString(b []byte) string {
+	return strings.TrimRight(string(b), "\x00")
+}
+
+// element is a child element of buffered parent.
+type element struct {
+	id   uint32
+	data []byte
+}
+
+// parseChildren splits data of master element into its children.
+func parseChildren(data []byte) ([]element, error) {
+	var children []element
+	for len(data) > 0 {
+		n := vintLen(data[0])
+		if n > 4 || len(data) < n {
+			return nil, errors.New("invalid element ID")
+		}
+		id, _ := readUint(data[:n])
+		data = data[n:]
+		size, n, unknown, err := parseVint(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n:]
+		if unknown || size > uint64(len(data)) {
+			return nil, fmt.Errorf("element %x exceeds its parent", id)
+		}
+		children = append(children, element{id: uint32(id), data: data[:size]})
+		data = data[size:]
+	}
+	return children, nil
+}
@@ -0,0 +1,124 @@
+package matroska
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// vintN encodes v in variable size integer of n bytes.
+func vintN(v uint64, n int) []byte {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	b[0] |= 0x80 >> (n - 1)
+	return b
+}
+
+// elementID encodes id, whose length marker is kept.
+func elementID(id uint32) []byte {
+	b := binary.BigEndian.AppendUint32(nil, id)
+	for len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+	return b
+}
+
+// el encodes an element of id whose data is the concatenation of children.
+func el(id uint32, children ...[]byte) []byte {
+	data := bytes.Join(children, nil)
+	b := append(elementID(id), vintN(uint64(len(data)), 8)...)
+	return append(b, data...)
+}
+
+// elUnknown encodes the header of element of unknown size, followed by children.
+func elUnknown(id uint32, children ...[]byte) []byte {
+	b := append(elementID(id), vintN(1<<56-1, 8)...)
+	return append(b, bytes.Join(children, nil)...)
+}
+
+func uintEl(id uint32, v uint64) []byte {
+	return el(id, binary.BigEndian.AppendUint64(nil, v))
+}
+
+func intEl(id uint32, v int64) []byte {
+	return uintEl(id, uint64(v))
+}
+
+func floatEl(id uint32, v float64) []byte {
+	return el(id, binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+}
+
+func stringEl(id uint32, s string) []byte {
+	return el(id, []byte(s))
+}
+
+func TestParseVint(t *testing.T) {
+	for _, tt := range []struct {
+		in      []byte
+		v       uint64
+		n       int
+		allOnes bool
+		err     bool
+	}{
+		{[]byte{0x81}, 1, 1, false, false},
+		{[]byte{0xff}, 0x7f, 1, true, false},
+		{[]byte{0x40, 0x02}, 2, 2, false, false},
+		{[]byte{0x7f, 0xff}, 0x3fff, 2, true, false},
+		{vintN(12345, 8), 12345, 8, false, false},
+		{vintN(1<<56-1, 8), 1<<56 - 1, 8, true, false},
+		{[]byte{0x00}, 0, 0, false, true},       // no length marker in the first byte
+		{[]byte{0x20, 0x01}, 0, 0, false, true}, // truncated
+		{nil, 0, 0, false, true},
+	} {
+		v, n, allOnes, err := parseVint(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%x: got no error", tt.in)
+			}
+			continue
+		}
+		if err != nil || v != tt.v || n != tt.n || allOnes != tt.allOnes {
+			t.Errorf("%x: got %d, %d, %t, %v, want %d, %d, %t", tt.in, v, n, allOnes, err, tt.v, tt.n, tt.allOnes)
+		}
+	}
+}
+
+func TestReadNumbers(t *testing.T) {
+	if v, err := readInt([]byte{0xff, 0xfe}); err != nil || v != -2 {
+		t.Errorf("readInt: got %d, %v, want -2", v, err)
+	}
+	if v, err := readInt([]byte{0x7f}); err != nil || v != 127 {
+		t.Errorf("readInt: got %d, %v, want 127", v, err)
+	}
+	if _, err := readUint(make([]byte, 9)); err == nil {
+		t.Error("readUint: no error for 9 bytes")
+	}
+	if v, err := readFloat(binary.BigEndian.AppendUint32(nil, math.Float32bits(44100))); err != nil || v != 44100 {
+		t.Errorf("readFloat: got %g, %v, want 44100", v, err)
+	}
+	if _, err := readFloat(make([]byte, 3)); err == nil {
+		t.Error("readFloat: no error for 3 bytes")
+	}
+}
+
+func TestParseChildren(t *testing.T) {
+	data := append(uintEl(idTrackNumber, 1), stringEl(idCodecID, "A_VORBIS")...)
+	children, err := parseChildren(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(children) != 2 || children[0].id != idTrackNumber || readString(children[1].data) != "A_VORBIS" {
+		t.Errorf("got children %v", children)
+	}
+	// a child exceeding its parent
+	if _, err := parseChildren(data[:len(data)-1]); err == nil {
+		t.Error("no error for truncated child")
+	}
+	if _, err := parseChildren(elUnknown(idAudio)); err == nil {
+		t.Error("no error for child of unknown size")
+	}
+}
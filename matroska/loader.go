@@ -0,0 +1,451 @@
+// Package matroska is a minimal demuxer of Matroska and WebM, for extracting audio frames.
+package matroska
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/sr8e/vorbis/load"
+)
+
+type MatroskaLoader struct {
+	load.BinaryLoader
+	DocType string // "matroska" or "webm"
+	// TimecodeScale is the duration of a unit of timestamps in nanoseconds.
+	TimecodeScale uint64
+	Tracks        []Track
+
+	// MaxElementSize limits the size of elements read into memory, such as blocks and codec private data.
+	// DefaultMaxElementSize is used if zero, and elements are not limited if negative.
+	MaxElementSize int
+
+	segmentEnd  int64 // unknownSize if not specified
+	inCluster   bool
+	clusterEnd  int64 // unknownSize if not specified
+	clusterTime int64
+	peeked      *header // element header read ahead, which ends cluster of unknown size
+	frames      []Frame // frames of laced block not returned yet
+}
+
+const DefaultMaxElementSize = 16 << 20
+
+type Track struct {
+	Number       uint64
+	Type         uint64 // 1 for video, 2 for audio
+	CodecID      string // such as "A_VORBIS"
+	CodecPrivate []byte
+	// CodecDelay is the duration to be discarded from the beginning of decoded samples.
+	CodecDelay        time.Duration
+	SamplingFrequency float64
+	Channels          uint64
+}
+
+const TrackTypeAudio = 2
+
+// Frame is a frame of a track extracted from block.
+type Frame struct {
+	Track uint64
+	// Timestamp is the time of the block. Every frame in a laced block has the timestamp of the first frame.
+	Timestamp time.Duration
+	Keyframe  bool
+	// DiscardPadding is the duration to be discarded from the end of decoded samples of the block.
+	DiscardPadding time.Duration
+	Data           []byte
+}
+
+// header is the header of element read from the source.
+type header struct {
+	id     uint32
+	size   int64 // unknownSize if not specified
+	offset int64 // offset of the element data
+}
+
+// ReadHeaders reads the EBML header and segment up to the first cluster, which contain track entries.
+func (ml *MatroskaLoader) ReadHeaders() error {
+	ml.TimecodeScale = 1000000
+	ml.Tracks = nil
+
+	h, err := ml.readHeader()
+	if err != nil {
+		return err
+	}
+	if h.id != idEBML {
+		return errors.New("not an EBML document")
+	}
+	data, err := ml.readData(h)
+	if err != nil {
+		return err
+	}
+	children, err := parseChildren(data)
+	if err != nil {
+		return err
+	}
+	ml.DocType = "matroska"
+	for _, c := range children {
+		if c.id == idDocType {
+			ml.DocType = readString(c.data)
+		}
+	}
+	if ml.DocType != "matroska" && ml.DocType != "webm" {
+		return fmt.Errorf("unsupported document type %q", ml.DocType)
+	}
+
+	for {
+		h, err = ml.readHeader()
+		if err != nil {
+			return err
+		}
+		if h.id == idSegment {
+			break
+		}
+		if err := ml.skip(h); err != nil {
+			return err
+		}
+	}
+	ml.segmentEnd = unknownSize
+	if h.size != unknownSize {
+		ml.segmentEnd = h.offset + h.size
+	}
+
+	for {
+		h, err := ml.nextSegmentChild()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch h.id {
+		case idInfo:
+			err = ml.readInfo(h)
+		case idTracks:
+			err = ml.readTracks(h)
+		case idCluster:
+			ml.enterCluster(h)
+			return nil
+		default:
+			err = ml.skip(h)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// NextFrame returns the next frame of any track, or io.EOF at the end of segment.
+func (ml *MatroskaLoader) NextFrame() (Frame, error) {
+	for len(ml.frames) == 0 {
+		if err := ml.readBlock(); err != nil {
+			return Frame{}, err
+		}
+	}
+	f := ml.frames[0]
+	ml.frames = ml.frames[1:]
+	return f, nil
+}
+
+// readBlock reads elements until a block, and sets its frames.
+func (ml *MatroskaLoader) readBlock() error {
+	for {
+		if !ml.inCluster {
+			h, err := ml.nextSegmentChild()
+			if err != nil {
+				return err
+			}
+			if h.id == idCluster {
+				ml.enterCluster(h)
+			} else if err := ml.skip(h); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if ml.clusterEnd != unknownSize && ml.Tell() >= ml.clusterEnd {
+			ml.inCluster = false
+			continue
+		}
+		h, err := ml.readHeader()
+		if errors.Is(err, io.EOF) && ml.clusterEnd == unknownSize {
+			return io.EOF
+		}
+		if err != nil {
+			return err
+		}
+		if ml.clusterEnd == unknownSize && isTopLevel(h.id) {
+			// cluster of unknown size ends at the next element of upper level
+			ml.inCluster = false
+			ml.peeked = &h
+			continue
+		}
+
+		switch h.id {
+		case idTimecode:
+			data, err := ml.readData(h)
+			if err != nil {
+				return err
+			}
+			v, err := readUint(data)
+			if err != nil {
+				return err
+			}
+			ml.clusterTime = int64(v)
+		case idSimpleBlock:
+			data, err := ml.readData(h)
+			if err != nil {
+				return err
+			}
+			frames, keyframe, err := ml.parseBlock(data, true)
+			if err != nil {
+				return err
+			}
+			for i := range frames {
+				frames[i].Keyframe = keyframe
+			}
+			ml.frames = frames
+			return nil
+		case idBlockGroup:
+			data, err := ml.readData(h)
+			if err != nil {
+				return err
+			}
+			return ml.readBlockGroup(data)
+		default:
+			if err := ml.skip(h); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (ml *MatroskaLoader) readBlockGroup(data []byte) error {
+	children, err := parseChildren(data)
+	if err != nil {
+		return err
+	}
+	var frames []Frame
+	keyframe := true
+	var discard int64
+	for _, c := range children {
+		switch c.id {
+		case idBlock:
+			frames, _, err = ml.parseBlock(c.data, false)
+			if err != nil {
+				return err
+			}
+		case idReferenceBlock:
+			keyframe = false
+		case idDiscardPadding:
+			discard, err = readInt(c.data)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if frames == nil {
+		return errors.New("block group has no block")
+	}
+	for i := range frames {
+		frames[i].Keyframe = keyframe
+	}
+	// padding applies to the end of the block
+	frames[len(frames)-1].DiscardPadding = time.Duration(discard)
+	ml.frames = frames
+	return nil
+}
+
+// nextSegmentChild returns the header of the next child of segment, or io.EOF at the end of segment.
+func (ml *MatroskaLoader) nextSegmentChild() (header, error) {
+	if ml.peeked != nil {
+		h := *ml.peeked
+		ml.peeked = nil
+		if h.id == idSegment || h.id == idEBML { // chained segment is not read
+			return header{}, io.EOF
+		}
+		return h, nil
+	}
+	if ml.segmentEnd != unknownSize && ml.Tell() >= ml.segmentEnd {
+		return header{}, io.EOF
+	}
+	h, err := ml.readHeader()
+	if err != nil {
+		return header{}, err
+	}
+	if h.id == idSegment || h.id == idEBML {
+		return header{}, io.EOF
+	}
+	return h, nil
+}
+
+func (ml *MatroskaLoader) enterCluster(h header) {
+	ml.inCluster = true
+	ml.clusterTime = 0
+	ml.clusterEnd = unknownSize
+	if h.size != unknownSize {
+		ml.clusterEnd = h.offset + h.size
+	}
+}
+
+func (ml *MatroskaLoader) readInfo(h header) error {
+	data, err := ml.readData(h)
+	if err != nil {
+		return err
+	}
+	children, err := parseChildren(data)
+	if err != nil {
+		return err
+	}
+	for _, c := range children {
+		if c.id == idTimecodeScale {
+			ml.TimecodeScale, err = readUint(c.data)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if ml.TimecodeScale == 0 {
+		return errors.New("timecode scale is zero")
+	}
+	return nil
+}
+
+func (ml *MatroskaLoader) readTracks(h header) error {
+	data, err := ml.readData(h)
+	if err != nil {
+		return err
+	}
+	entries, err := parseChildren(data)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.id != idTrackEntry {
+			continue
+		}
+		t, err := parseTrack(e.data)
+		if err != nil {
+			return err
+		}
+		ml.Tracks = append(ml.Tracks, t)
+	}
+	return nil
+}
+
+func parseTrack(data []byte) (t Track, err error) {
+	t.SamplingFrequency = 8000
+	t.Channels = 1
+	children, err := parseChildren(data)
+	if err != nil {
+		return
+	}
+	for _, c := range children {
+		var v uint64
+		switch c.id {
+		case idTrackNumber:
+			t.Number, err = readUint(c.data)
+		case idTrackType:
+			t.Type, err = readUint(c.data)
+		case idCodecID:
+			t.CodecID = readString(c.data)
+		case idCodecPrivate:
+			t.CodecPrivate = c.data
+		case idCodecDelay:
+			v, err = readUint(c.data)
+			t.CodecDelay = time.Duration(v)
+		case idAudio:
+			var audio []element
+			audio, err = parseChildren(c.data)
+			for _, a := range audio {
+				if err != nil {
+					break
+				}
+				switch a.id {
+				case idSamplingFrequency:
+					t.SamplingFrequency, err = readFloat(a.data)
+				case idChannels:
+					t.Channels, err = readUint(a.data)
+				}
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// readHeader reads ID and size of the element at the current offset.
+// It returns io.EOF if the source ends before the element.
+func (ml *MatroskaLoader) readHeader() (header, error) {
+	b, err := ml.GetBytes(1)
+	if errors.Is(err, io.EOF) {
+		return header{}, io.EOF
+	}
+	if err != nil {
+		return header{}, err
+	}
+	n := vintLen(b[0])
+	if n > 4 {
+		return header{}, fmt.Errorf("invalid element ID at offset %d", ml.Tell()-1)
+	}
+	rest, err := ml.GetBytes(n - 1)
+	if err != nil {
+		return header{}, err
+	}
+	id, _ := readUint(append(b, rest...))
+
+	b, err = ml.GetBytes(1)
+	if err != nil {
+		return header{}, err
+	}
+	n = vintLen(b[0])
+	if n > 8 {
+		return header{}, fmt.Errorf("invalid element size at offset %d", ml.Tell()-1)
+	}
+	rest, err = ml.GetBytes(n - 1)
+	if err != nil {
+		return header{}, err
+	}
+	size, _, unknown, err := parseVint(append(b, rest...))
+	if err != nil {
+		return header{}, err
+	}
+	h := header{id: uint32(id), size: int64(size), offset: ml.Tell()}
+	if unknown {
+		h.size = unknownSize
+	} else if size > 1<<56 {
+		return header{}, fmt.Errorf("element size too large at offset %d", h.offset)
+	}
+	return h, nil
+}
+
+// readData reads the whole data of element into memory.
+func (ml *MatroskaLoader) readData(h header) ([]byte, error) {
+	if h.size == unknownSize {
+		return nil, fmt.Errorf("element %x at offset %d has unknown size", h.id, h.offset)
+	}
+	limit := ml.MaxElementSize
+	if limit == 0 {
+		limit = DefaultMaxElementSize
+	}
+	if limit > 0 && h.size > int64(limit) {
+		return nil, fmt.Errorf("element %x at offset %d exceeds size limit: %d", h.id, h.offset, h.size)
+	}
+	return ml.GetBytes(int(h.size))
+}
+
+// skip skips the data of element, which is not used.
+func (ml *MatroskaLoader) skip(h header) error {
+	if h.size == unknownSize {
+		return fmt.Errorf("cannot skip element %x of unknown size at offset %d", h.id, h.offset)
+	}
+	for rest := h.size; rest > 0; {
+		n := min(rest, 1<<16)
+		if _, err := ml.GetBytes(int(n)); err != nil {
+			return err
+		}
+		rest -= n
+	}
+	return nil
+}
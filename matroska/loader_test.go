@@ -0,0 +1,201 @@
+package matroska
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// block encodes SimpleBlock or Block of track with the timestamp rel to the cluster,
+// whose frames are laced as flags select.
+func block(track uint64, rel int16, flags byte, frames ...[]byte) []byte {
+	b := vintN(track, 1)
+	b = binary.BigEndian.AppendUint16(b, uint16(rel))
+	b = append(b, flags)
+	n := len(frames) - 1
+	switch (flags >> 1) & 3 {
+	case lacingXiph:
+		b = append(b, byte(n))
+		for _, f := range frames[:n] {
+			size := len(f)
+			for ; size >= 255; size -= 255 {
+				b = append(b, 255)
+			}
+			b = append(b, byte(size))
+		}
+	case lacingFixed:
+		b = append(b, byte(n))
+	case lacingEBML:
+		b = append(b, byte(n))
+		for i, f := range frames[:n] {
+			if i == 0 {
+				b = append(b, vintN(uint64(len(f)), 2)...)
+				continue
+			}
+			// signed difference from the previous size, biased by half the range
+			diff := len(f) - len(frames[i-1])
+			b = append(b, vintN(uint64(diff+(1<<13-1)), 2)...)
+		}
+	}
+	for _, f := range frames {
+		b = append(b, f...)
+	}
+	return b
+}
+
+// frame returns data of size filled with v.
+func frame(v byte, size int) []byte {
+	return bytes.Repeat([]byte{v}, size)
+}
+
+// testHeaders encodes EBML header, and the beginning of segment of unknown size with Info and Tracks.
+func testHeaders(docType string, tracks ...[]byte) []byte {
+	b := el(idEBML, stringEl(idDocType, docType))
+	return append(b, elUnknown(idSegment,
+		el(idInfo, uintEl(idTimecodeScale, 1000000)),
+		el(idTracks, tracks...),
+	)...)
+}
+
+func readFrames(t *testing.T, src []byte) (*MatroskaLoader, []Frame) {
+	t.Helper()
+	var ml MatroskaLoader
+	if err := ml.OpenReader(bytes.NewReader(src)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ml.ReadHeaders(); err != nil {
+		t.Fatal(err)
+	}
+	var frames []Frame
+	for {
+		f, err := ml.NextFrame()
+		if errors.Is(err, io.EOF) {
+			return &ml, frames
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, f)
+	}
+}
+
+func TestNextFrame(t *testing.T) {
+	src := testHeaders("webm",
+		el(idTrackEntry,
+			uintEl(idTrackNumber, 1),
+			uintEl(idTrackType, TrackTypeAudio),
+			stringEl(idCodecID, "A_TEST"),
+			uintEl(idCodecDelay, 6500000),
+			el(idAudio, floatEl(idSamplingFrequency, 48000), uintEl(idChannels, 2)),
+		),
+		el(idTrackEntry, uintEl(idTrackNumber, 2), uintEl(idTrackType, 1), stringEl(idCodecID, "V_TEST")),
+	)
+	src = append(src, el(idCluster,
+		uintEl(idTimecode, 1000),
+		el(idSimpleBlock, block(1, 0, 0x80, frame(1, 40))),
+		el(idSimpleBlock, block(2, 5, 0x80, frame(2, 10))),
+		el(idSimpleBlock, block(1, 20, lacingXiph<<1, frame(3, 300), frame(4, 0), frame(5, 255), frame(6, 7))),
+		el(idSimpleBlock, block(1, 40, lacingFixed<<1, frame(7, 100), frame(8, 100), frame(9, 100))),
+	)...)
+	// cluster of unknown size ends at the next element of upper level
+	src = append(src, elUnknown(idCluster,
+		uintEl(idTimecode, 2000),
+		el(idSimpleBlock, block(1, -10, 0x80|lacingEBML<<1, frame(10, 300), frame(11, 10), frame(12, 0), frame(13, 600), frame(14, 45))),
+		el(idBlockGroup,
+			el(idBlock, block(1, 10, 0, frame(15, 30))),
+			intEl(idReferenceBlock, -10),
+			intEl(idDiscardPadding, 5000000),
+		),
+	)...)
+	src = append(src, el(idCues)...)
+	src = append(src, elUnknown(idCluster,
+		uintEl(idTimecode, 3000),
+		el(idBlockGroup, el(idBlock, block(1, 0, 0, frame(16, 20)))),
+	)...)
+
+	ml, got := readFrames(t, src)
+	if ml.DocType != "webm" || ml.TimecodeScale != 1000000 || len(ml.Tracks) != 2 {
+		t.Fatalf("got doc type %q, timecode scale %d, %d tracks", ml.DocType, ml.TimecodeScale, len(ml.Tracks))
+	}
+	wantTrack := Track{Number: 1, Type: TrackTypeAudio, CodecID: "A_TEST", CodecDelay: 6500 * time.Microsecond, SamplingFrequency: 48000, Channels: 2}
+	if tr := ml.Tracks[0]; tr.Number != wantTrack.Number || tr.Type != wantTrack.Type || tr.CodecID != wantTrack.CodecID ||
+		tr.CodecDelay != wantTrack.CodecDelay || tr.SamplingFrequency != wantTrack.SamplingFrequency || tr.Channels != wantTrack.Channels {
+		t.Errorf("got track %+v, want %+v", tr, wantTrack)
+	}
+
+	ms := time.Millisecond
+	want := []Frame{
+		{1, 1000 * ms, true, 0, frame(1, 40)},
+		{2, 1005 * ms, true, 0, frame(2, 10)},
+		// Xiph lacing
+		{1, 1020 * ms, false, 0, frame(3, 300)},
+		{1, 1020 * ms, false, 0, frame(4, 0)},
+		{1, 1020 * ms, false, 0, frame(5, 255)},
+		{1, 1020 * ms, false, 0, frame(6, 7)},
+		// fixed lacing
+		{1, 1040 * ms, false, 0, frame(7, 100)},
+		{1, 1040 * ms, false, 0, frame(8, 100)},
+		{1, 1040 * ms, false, 0, frame(9, 100)},
+		// EBML lacing
+		{1, 1990 * ms, true, 0, frame(10, 300)},
+		{1, 1990 * ms, true, 0, frame(11, 10)},
+		{1, 1990 * ms, true, 0, frame(12, 0)},
+		{1, 1990 * ms, true, 0, frame(13, 600)},
+		{1, 1990 * ms, true, 0, frame(14, 45)},
+		{1, 2010 * ms, false, 5 * ms, frame(15, 30)},
+		{1, 3000 * ms, true, 0, frame(16, 20)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d frames, want %d", len(got), len(want))
+	}
+	for i, f := range got {
+		w := want[i]
+		if f.Track != w.Track || f.Timestamp != w.Timestamp || f.Keyframe != w.Keyframe ||
+			f.DiscardPadding != w.DiscardPadding || !bytes.Equal(f.Data, w.Data) {
+			t.Errorf("frame %d: got track %d at %v, keyframe %t, padding %v, %d bytes, want track %d at %v, keyframe %t, padding %v, %d bytes",
+				i, f.Track, f.Timestamp, f.Keyframe, f.DiscardPadding, len(f.Data), w.Track, w.Timestamp, w.Keyframe, w.DiscardPadding, len(w.Data))
+		}
+	}
+}
+
+func TestReadHeadersInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		src  []byte
+	}{
+		{"not EBML", el(idSegment)},
+		{"unsupported doc type", testHeaders("mp4")},
+		{"zero timecode scale", append(el(idEBML), el(idSegment, el(idInfo, uintEl(idTimecodeScale, 0)))...)},
+		{"truncated", testHeaders("webm", el(idTrackEntry, uintEl(idTrackNumber, 1)))[:60]},
+	} {
+		var ml MatroskaLoader
+		if err := ml.OpenReader(bytes.NewReader(tt.src)); err != nil {
+			t.Fatal(err)
+		}
+		if err := ml.ReadHeaders(); err == nil {
+			t.Errorf("%s: got no error", tt.name)
+		}
+	}
+}
+
+func TestUnlaceInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		unlace func([]byte) ([][]byte, error)
+		data   []byte
+	}{
+		{"fixed empty", unlaceFixed, nil},
+		{"fixed not divisible", unlaceFixed, []byte{1, 0, 0, 0}},
+		{"EBML empty", unlaceEBML, nil},
+		{"EBML size exceeding data", unlaceEBML, append([]byte{1}, vintN(10, 1)...)},
+		{"EBML negative size", unlaceEBML, append(append([]byte{2}, vintN(1, 1)...), append(vintN(1<<13-3, 2), 0, 0)...)},
+		{"EBML sizes exceeding data", unlaceEBML, append(append([]byte{2}, vintN(2, 1)...), append(vintN(1<<13-1, 2), 0, 0, 0)...)},
+	} {
+		if _, err := tt.unlace(tt.data); err == nil {
+			t.Errorf("%s: got no error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package matroska
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/sr8e/vorbis/ogg"
+	"github.com/sr8e/vorbis/vorbis"
+)
+
+const CodecVorbis = "A_VORBIS"
+
+// VorbisTrack returns the first Vorbis track.
+func (ml *MatroskaLoader) VorbisTrack() (Track, bool) {
+	for _, t := range ml.Tracks {
+		if t.CodecID == CodecVorbis {
+			return t, true
+		}
+	}
+	return Track{}, false
+}
+
+// vorbisHeaders unpacks the header packets in codec private data of Vorbis track.
+func vorbisHeaders(t Track) ([]ogg.Packet, error) {
+	if t.CodecID != CodecVorbis {
+		return nil, fmt.Errorf("%w: codec of track %d is %s", vorbis.ErrNotVorbis, t.Number, t.CodecID)
+	}
+	return vorbis.UnpackHeaders(t.CodecPrivate)
+}
+
+// NewVorbisDecoder returns the decoder of Vorbis track, from the headers in its codec private data read with opts.
+func NewVorbisDecoder(t Track, opts vorbis.HeaderOptions) (*vorbis.Decoder, error) {
+	headers, err := vorbisHeaders(t)
+	if err != nil {
+		return nil, err
+	}
+	return vorbis.NewDecoder(headers[0].Bytes(), headers[1].Bytes(), headers[2].Bytes(), opts)
+}
+
+// samplesOf converts duration into the number of samples at rate, rounding down.
+func samplesOf(d time.Duration, rate uint32) int {
+	return int(int64(d) * int64(rate) / int64(time.Second))
+}
+
+// DecodeVorbis decodes all frames of Vorbis track t read from ml after ReadHeaders,
+// and returns samples for each channel. Samples of CodecDelay of the track at the beginning,
+// and padding at the end specified by blocks, are discarded. Headers are read with opts.
+func DecodeVorbis(ml *MatroskaLoader, t Track, opts vorbis.HeaderOptions) ([][]float32, error) {
+	d, err := NewVorbisDecoder(t, opts)
+	if err != nil {
+		return nil, err
+	}
+	samples := make([][]float32, d.Identification.Channels)
+	skip := samplesOf(t.CodecDelay, d.Identification.SampleRate)
+	for {
+		f, err := ml.NextFrame()
+		if errors.Is(err, io.EOF) {
+			return samples, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if f.Track != t.Number {
+			continue
+		}
+		chunk, err := d.DecodePacket(f.Data)
+		if err != nil {
+			return nil, err
+		}
+		n := len(chunk[0])
+		if f.DiscardPadding > 0 {
+			n = max(n-samplesOf(f.DiscardPadding, d.Identification.SampleRate), 0)
+		}
+		start := min(skip, n)
+		skip -= start
+		for ch, v := range chunk {
+			samples[ch] = append(samples[ch], v[start:n]...)
+		}
+	}
+}
+
+// RemuxVorbis writes Vorbis track t read from ml after ReadHeaders to w, as an Ogg stream of serial.
+// Granule positions are computed from block sizes of packets,
+// and the position of the last page is trimmed by the padding specified by the last block.
+// Headers are read with opts.
+func RemuxVorbis(ml *MatroskaLoader, t Track, w io.Writer, serial uint32, opts vorbis.HeaderOptions) error {
+	headers, err := vorbisHeaders(t)
+	if err != nil {
+		return err
+	}
+	d, err := vorbis.NewDecoder(headers[0].Bytes(), headers[1].Bytes(), headers[2].Bytes(), opts)
+	if err != nil {
+		return err
+	}
+	rate := d.Identification.SampleRate
+
+	granule := vorbis.NewGranuleFunc()
+	pw := ogg.NewPacketWriter(w, serial)
+	// the identification header takes the first page alone, and audio begins on a new page
+	for i := range headers {
+		p := headers[i]
+		if _, ok := granule(&p); !ok {
+			return fmt.Errorf("%w: invalid header packet %d", vorbis.ErrNotVorbis, i)
+		}
+		if err := pw.WritePacket(headers[i].Bytes(), 0); err != nil {
+			return err
+		}
+		if i == 0 {
+			if err := pw.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := pw.Flush(); err != nil {
+		return err
+	}
+
+	// the last packet is held until the end is known
+	var last Frame
+	var lastPos, prevPos uint64
+	held := false
+	for {
+		f, err := ml.NextFrame()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if f.Track != t.Number {
+			continue
+		}
+		p := ogg.NewPacket(f.Data)
+		pos, ok := granule(&p)
+		if !ok {
+			return fmt.Errorf("malformed audio packet at %v", f.Timestamp)
+		}
+		if held {
+			if err := pw.WritePacket(last.Data, lastPos); err != nil {
+				return err
+			}
+			prevPos = lastPos
+		}
+		last, lastPos, held = f, pos, true
+	}
+	if held {
+		if last.DiscardPadding > 0 {
+			trim := uint64(samplesOf(last.DiscardPadding, rate))
+			lastPos = max(lastPos-min(trim, lastPos), prevPos)
+		}
+		if err := pw.WritePacket(last.Data, lastPos); err != nil {
+			return err
+		}
+	}
+	return pw.Close()
+}
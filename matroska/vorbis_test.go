@@ -0,0 +1,184 @@
+package matroska
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sr8e/vorbis/vorbis"
+)
+
+// durationOf converts the number of samples at rate into duration, rounding up, so that samplesOf restores it.
+func durationOf(samples int, rate uint32) time.Duration {
+	return time.Duration((int64(samples)*int64(time.Second) + int64(rate) - 1) / int64(rate))
+}
+
+// testVorbis is the Vorbis stream in testdata, and its samples decoded from Ogg.
+type testVorbis struct {
+	private []byte
+	packets [][]byte // audio packets
+	rate    uint32
+	ref     [][]float32
+	// padding is the number of samples decoded beyond the end of stream
+	padding int
+}
+
+func loadTestVorbis(t *testing.T) testVorbis {
+	t.Helper()
+	data, err := os.ReadFile("../testdata/test.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vd, err := vorbis.NewVorbisDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := vd.DecodeAllFloat32()
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := vorbis.PackHeaders(vd.Packets[:3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	tv := testVorbis{private: private, rate: vd.Identification.SampleRate, ref: ref}
+
+	// samples of raw decoder are not trimmed at the end
+	d, err := vorbis.NewDecoder(vd.Packets[0].Bytes(), vd.Packets[1].Bytes(), vd.Packets[2].Bytes(), vorbis.HeaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for i := 3; i < len(vd.Packets); i++ {
+		tv.packets = append(tv.packets, vd.Packets[i].Bytes())
+		chunk, err := d.DecodePacket(vd.Packets[i].Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += len(chunk[0])
+	}
+	tv.padding = total - len(ref[0])
+	if tv.padding <= 0 {
+		t.Fatalf("no padding in test stream: decoded %d samples, %d in Ogg", total, len(ref[0]))
+	}
+	return tv
+}
+
+// webm encodes the stream as WebM in every lacing, with a frame of another track between,
+// and the padding at the end in the last block.
+func (tv testVorbis) webm(codecDelay time.Duration) []byte {
+	src := testHeaders("webm",
+		el(idTrackEntry,
+			uintEl(idTrackNumber, 1),
+			uintEl(idTrackType, 1),
+			stringEl(idCodecID, "V_TEST"),
+		),
+		el(idTrackEntry,
+			uintEl(idTrackNumber, 2),
+			uintEl(idTrackType, TrackTypeAudio),
+			stringEl(idCodecID, CodecVorbis),
+			el(idCodecPrivate, tv.private),
+			uintEl(idCodecDelay, uint64(codecDelay)),
+			el(idAudio, floatEl(idSamplingFrequency, float64(tv.rate)), uintEl(idChannels, uint64(len(tv.ref)))),
+		),
+	)
+	packets := tv.packets
+	last := packets[len(packets)-1]
+	packets = packets[:len(packets)-1]
+
+	var blocks [][]byte
+	for i := 0; i < 2; i++ {
+		blocks = append(blocks, el(idSimpleBlock, block(2, 0, 0x80, packets[i])))
+	}
+	blocks = append(blocks, el(idSimpleBlock, block(1, 0, 0x80, frame(0, 10))))
+	lacings := []byte{lacingXiph, lacingEBML}
+	for i, j := 2, 0; i < len(packets); i, j = i+4, j+1 {
+		blocks = append(blocks, el(idSimpleBlock, block(2, 0, 0x80|lacings[j%2]<<1, packets[i:min(i+4, len(packets))]...)))
+	}
+	blocks = append(blocks, el(idBlockGroup,
+		el(idBlock, block(2, 0, 0, last)),
+		intEl(idDiscardPadding, int64(durationOf(tv.padding, tv.rate))),
+	))
+	return append(src, elUnknown(idCluster, append([][]byte{uintEl(idTimecode, 0)}, blocks...)...)...)
+}
+
+func openVorbisTrack(t *testing.T, src []byte) (*MatroskaLoader, Track) {
+	t.Helper()
+	var ml MatroskaLoader
+	if err := ml.OpenReader(bytes.NewReader(src)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ml.ReadHeaders(); err != nil {
+		t.Fatal(err)
+	}
+	tr, ok := ml.VorbisTrack()
+	if !ok {
+		t.Fatal("no Vorbis track")
+	}
+	return &ml, tr
+}
+
+func TestDecodeVorbis(t *testing.T) {
+	tv := loadTestVorbis(t)
+	for _, delay := range []int{0, 1000} {
+		ml, tr := openVorbisTrack(t, tv.webm(durationOf(delay, tv.rate)))
+		got, err := DecodeVorbis(ml, tr, vorbis.HeaderOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tv.ref) {
+			t.Fatalf("delay %d: got %d channels, want %d", delay, len(got), len(tv.ref))
+		}
+		for ch := range got {
+			if want := tv.ref[ch][delay:]; !slices.Equal(got[ch], want) {
+				t.Errorf("delay %d: channel %d differs from Ogg: got %d samples, want %d", delay, ch, len(got[ch]), len(want))
+			}
+		}
+	}
+}
+
+// remuxed stream decodes to the same samples as the source in Ogg, trimmed by the padding of the last block.
+func TestRemuxVorbis(t *testing.T) {
+	tv := loadTestVorbis(t)
+	ml, tr := openVorbisTrack(t, tv.webm(0))
+	var buf bytes.Buffer
+	if err := RemuxVorbis(ml, tr, &buf, 1, vorbis.HeaderOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	vd, err := vorbis.NewVorbisDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vd.Packets) != len(tv.packets)+3 {
+		t.Fatalf("got %d packets, want %d", len(vd.Packets), len(tv.packets)+3)
+	}
+	for i, p := range tv.packets {
+		if !bytes.Equal(vd.Packets[i+3].Bytes(), p) {
+			t.Errorf("audio packet %d differs", i)
+		}
+	}
+	if granule, ok := vd.Packets[len(vd.Packets)-1].Granule(); !ok || granule != uint64(len(tv.ref[0])) {
+		t.Errorf("got last granule %d, %t, want %d", granule, ok, len(tv.ref[0]))
+	}
+	got, err := vd.DecodeAllFloat32()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ch := range got {
+		if !slices.Equal(got[ch], tv.ref[ch]) {
+			t.Errorf("channel %d differs from source: got %d samples, want %d", ch, len(got[ch]), len(tv.ref[ch]))
+		}
+	}
+}
+
+func TestDecodeVorbisNotVorbis(t *testing.T) {
+	if _, err := NewVorbisDecoder(Track{CodecID: "A_OPUS"}, vorbis.HeaderOptions{}); err == nil {
+		t.Error("got no error for Opus track")
+	}
+	if _, err := NewVorbisDecoder(Track{CodecID: CodecVorbis, CodecPrivate: []byte{0}}, vorbis.HeaderOptions{}); err == nil {
+		t.Error("got no error for broken codec private data")
+	}
+}
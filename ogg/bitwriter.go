@@ -0,0 +1,49 @@
+package ogg
+
+// BitWriter packs values into bytes in the bit order Packet reads them,
+// from the least significant bit of each byte.
+type BitWriter struct {
+	data []byte
+	cur  uint32
+}
+
+// PutUint writes the lowest n bits of v. n is up to 32.
+func (w *BitWriter) PutUint(v uint32, n uint32) {
+	for i := uint32(0); i < n; {
+		bytePos := w.cur / 8
+		bitOfs := w.cur % 8
+		if int(bytePos) == len(w.data) {
+			w.data = append(w.data, 0)
+		}
+
+		maskLen := min(8-bitOfs, n-i)
+		w.data[bytePos] |= byte(v>>i) & mask[maskLen] << bitOfs
+
+		i += maskLen
+		w.cur += maskLen
+	}
+}
+
+func (w *BitWriter) PutFlag(f bool) {
+	if f {
+		w.PutUint(1, 1)
+	} else {
+		w.PutUint(0, 1)
+	}
+}
+
+func (w *BitWriter) PutBytes(b []byte) {
+	for _, v := range b {
+		w.PutUint(uint32(v), 8)
+	}
+}
+
+// Bytes returns the written bytes, with the last byte padded by zero.
+func (w *BitWriter) Bytes() []byte {
+	return w.data
+}
+
+// BitOffset returns the number of bits written.
+func (w *BitWriter) BitOffset() int {
+	return int(w.cur)
+}
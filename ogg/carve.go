@@ -0,0 +1,82 @@
+package ogg
+
+import (
+	"bytes"
+	"cmp"
+	"io"
+	"slices"
+)
+
+// Carve scans r for pages with valid checksum, skipping any foreign data, and groups them into streams by serial.
+// Pages of each stream are ordered by sequence, and duplicates of a page are dropped.
+// Since serials may be reused, as in chained captures, a beginning of stream page starts another stream
+// if the stream of the serial has begun already, and so does a page conflicting with the same sequence.
+// Streams are returned in order of their first page found.
+func Carve(r io.Reader) ([]Stream, error) {
+	ol := OggLoader{Lenient: true}
+	err := ol.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var streams []Stream
+	var seqs []map[uint32]*Page // pages of each stream by sequence
+	index := map[uint32][]int{} // streams of each serial
+	for {
+		p, err := ol.readPage()
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			break
+		}
+
+		dst := -1
+		candidates := index[p.stream]
+		for j := len(candidates) - 1; j >= 0 && dst == -1; j-- {
+			i := candidates[j]
+			q := seqs[i][p.seq]
+			switch {
+			case q != nil && samePage(p, q):
+				dst = -2 // duplicate
+			case q != nil:
+			case isBOS(p):
+				// the beginning is taken only by the latest stream not begun yet
+				if j == len(candidates)-1 && !slices.ContainsFunc(streams[i].pages, isBOS) {
+					dst = i
+				}
+			default:
+				// the latest stream without a page of the sequence takes the page
+				dst = i
+			}
+		}
+		switch {
+		case dst == -2:
+			continue
+		case dst < 0:
+			dst = len(streams)
+			index[p.stream] = append(index[p.stream], dst)
+			streams = append(streams, Stream{serial: p.stream})
+			seqs = append(seqs, map[uint32]*Page{})
+		}
+		streams[dst].pages = append(streams[dst].pages, p)
+		seqs[dst][p.seq] = p
+	}
+
+	for i := range streams {
+		slices.SortFunc(streams[i].pages, func(a, b *Page) int {
+			return cmp.Compare(a.seq, b.seq)
+		})
+	}
+	return streams, nil
+}
+
+func isBOS(p *Page) bool {
+	return p.streamFlag&1 != 0
+}
+
+// samePage reports whether pages of the same stream and sequence have the same content.
+func samePage(a, b *Page) bool {
+	return a.streamFlag == b.streamFlag && a.granule == b.granule &&
+		bytes.Equal(a.segLens, b.segLens) && bytes.Equal(a.body, b.body)
+}
@@ -0,0 +1,110 @@
+package ogg
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// writeStream returns pages of a stream of serial with count packets filled with fill.
+func writeStream(t *testing.T, serial uint32, count int, fill byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	pw := NewPacketWriter(&buf, serial)
+	pw.PageSize = 100
+	for i := 0; i < count; i++ {
+		if err := pw.WritePacket(bytes.Repeat([]byte{fill}, 60), uint64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// checkCarved checks that each stream consists of packets filled with the corresponding byte.
+func checkCarved(t *testing.T, streams []Stream, count int, fills ...byte) {
+	t.Helper()
+	if len(streams) != len(fills) {
+		t.Fatalf("got %d streams, want %d", len(streams), len(fills))
+	}
+	for i, s := range streams {
+		if gaps := s.Gaps(); len(gaps) > 0 {
+			t.Errorf("stream %d: gaps %v", i, gaps)
+		}
+		packets, err := s.GetPackets()
+		if err != nil {
+			t.Fatalf("stream %d: %v", i, err)
+		}
+		if len(packets) != count {
+			t.Errorf("stream %d: got %d packets, want %d", i, len(packets), count)
+		}
+		for j, p := range packets {
+			if !bytes.Equal(p.Bytes(), bytes.Repeat([]byte{fills[i]}, 60)) {
+				t.Errorf("stream %d: packet %d differs", i, j)
+				break
+			}
+		}
+	}
+}
+
+func TestCarveDuplicates(t *testing.T) {
+	data, err := os.ReadFile("../testdata/test.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := append(append(append([]byte(nil), data...), "garbage"...), data...)
+	streams, err := Carve(bytes.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 1 {
+		t.Fatalf("got %d streams, want 1", len(streams))
+	}
+	var buf bytes.Buffer
+	if _, err := streams[0].WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("carved stream differs from the source")
+	}
+}
+
+func TestCarveChained(t *testing.T) {
+	a := writeStream(t, 1, 20, 'a')
+	b := writeStream(t, 1, 20, 'b')
+	c := writeStream(t, 2, 20, 'c')
+
+	streams, err := Carve(bytes.NewReader(append(append(append([]byte(nil), a...), c...), b...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCarved(t, streams, 20, 'a', 'c', 'b')
+
+	// pages differing from those of the same sequence start another stream without the beginning
+	pages := splitPages(t, b)
+	rest := bytes.Join(pages[1:], nil)
+	streams, err = Carve(bytes.NewReader(append(append([]byte(nil), a...), rest...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 2 || streams[1].NumPages() != len(pages)-1 {
+		t.Fatalf("got %d streams, want 2 streams of %d and %d pages", len(streams), len(pages), len(pages)-1)
+	}
+}
+
+// splitPages splits data into pages.
+func splitPages(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	var pages [][]byte
+	for len(data) > 0 {
+		size := 27 + int(data[26])
+		for _, l := range data[27 : 27+int(data[26])] {
+			size += int(l)
+		}
+		pages = append(pages, data[:size])
+		data = data[size:]
+	}
+	return pages
+}
@@ -0,0 +1,27 @@
+package ogg
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrCapturePattern = errors.New("cannot capture page header")
+	ErrChecksum       = errors.New("checksum does not match")
+	ErrNotFollowable  = errors.New("memory-mapped source cannot be followed")
+)
+
+// PageError reports an invalid page, or failure of reading it.
+type PageError struct {
+	Offset int64  // byte offset of the page in source
+	Serial uint32 // serial of the stream, 0 if the page header is not read
+	Err    error
+}
+
+func (e *PageError) Error() string {
+	return fmt.Sprintf("page at offset %d of stream %08x: %v", e.Offset, e.Serial, e.Err)
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,71 @@
+package ogg
+
+import (
+	"errors"
+)
+
+// XiphLace packs packets in Xiph lacing, used by Matroska and RTP:
+// the number of packets minus 1 in a byte, the size of each packet but the last,
+// coded as a run of 255 terminated by a value less than 255 like lacing values of Ogg,
+// and then payloads of the packets.
+func XiphLace(packets [][]byte) ([]byte, error) {
+	if len(packets) == 0 || len(packets) > 256 {
+		return nil, errors.New("number of packets must be 1 to 256")
+	}
+	total := 0
+	for _, p := range packets {
+		total += len(p)
+	}
+	b := make([]byte, 0, 1+total/255+len(packets)+total)
+	b = append(b, byte(len(packets)-1))
+	for _, p := range packets[:len(packets)-1] {
+		for n := len(p); ; n -= 255 {
+			if n < 255 {
+				b = append(b, byte(n))
+				break
+			}
+			b = append(b, 255)
+		}
+	}
+	for _, p := range packets {
+		b = append(b, p...)
+	}
+	return b, nil
+}
+
+// XiphUnlace unpacks packets packed by XiphLace. The last packet takes the rest of data.
+// Returned packets share memory with data.
+func XiphUnlace(data []byte) ([][]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty laced data")
+	}
+	n := int(data[0]) + 1
+	pos := 1
+	sizes := make([]int, n)
+	total := 0
+	for i := 0; i < n-1; i++ {
+		for {
+			if pos >= len(data) {
+				return nil, errors.New("lacing values are truncated")
+			}
+			v := data[pos]
+			pos++
+			sizes[i] += int(v)
+			if v < 255 {
+				break
+			}
+		}
+		total += sizes[i]
+	}
+	if total > len(data)-pos {
+		return nil, errors.New("laced packets exceed data")
+	}
+	sizes[n-1] = len(data) - pos - total
+
+	packets := make([][]byte, n)
+	for i, size := range sizes {
+		packets[i] = data[pos : pos+size : pos+size]
+		pos += size
+	}
+	return packets, nil
+}
@@ -1,10 +1,13 @@
 package ogg
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/sr8e/vorbis/crc"
 	"github.com/sr8e/vorbis/load"
@@ -13,6 +16,47 @@ import (
 type OggLoader struct {
 	load.BinaryLoader
 	Streams map[uint32]Stream
+
+	// Lenient makes the loader skip damaged regions, instead of failing.
+	// It scans forward for the next valid page, and reports what is skipped in Warnings.
+	Lenient  bool
+	Warnings []Warning
+	// IgnoreChecksum makes the loader accept pages with wrong checksum.
+	// In lenient mode, such a page is accepted only if followed by another page or EOF.
+	IgnoreChecksum bool
+
+	// Follow makes the loader wait for data appended to the source at EOF, like tail -f.
+	// Reading ends at the end of all streams started, or cancellation of context given to NextPacket.
+	// Memory-mapped source cannot be followed, since the mapping does not grow, and ErrNotFollowable is returned.
+	Follow bool
+	// PollInterval is the interval to check appended data in follow mode. DefaultPollInterval is used if zero.
+	PollInterval time.Duration
+	// MaxPacketSize limits the size of packets assembled from pages, and larger packets are dropped as lost.
+	// DefaultMaxPacketSize is used if zero, and packets are not limited if negative.
+	MaxPacketSize int
+
+	pending     []byte // bytes pushed back to be read again before the source
+	ctx         context.Context
+	ended       map[uint32]bool // whether each stream has reached its end
+	assemblers  map[uint32]*packetAssembler
+	ready       []Packet // packets completed but not returned by NextPacket
+	readySerial uint32
+}
+
+const (
+	DefaultPollInterval  = 100 * time.Millisecond
+	DefaultMaxPacketSize = 16 << 20
+)
+
+// Warning reports a region of source skipped in lenient mode.
+type Warning struct {
+	Offset int64 // offset of the region in source
+	Length int64 // length of the region in bytes
+	Reason string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s at offset %d (%d bytes)", w.Reason, w.Offset, w.Length)
 }
 
 type Page struct {
@@ -21,11 +65,20 @@ type Page struct {
 	granule    uint64
 	seq        uint32
 	packets    []Packet
+
+	offset      int64  // byte offset in source
+	segLens     []byte // segment table as read
+	body        []byte
+	badChecksum bool // checksum was wrong, which is accepted by IgnoreChecksum
 }
 
 func (ol *OggLoader) ReadAll() error {
+	if ol.Follow && ol.Mapped() {
+		return ErrNotFollowable
+	}
 	ol.Streams = map[uint32]Stream{}
-	for {
+	ol.ended = map[uint32]bool{}
+	for !ol.Follow || !ol.streamsEnded() {
 		p, err := ol.readPage()
 		if err != nil {
 			return err
@@ -33,8 +86,9 @@ func (ol *OggLoader) ReadAll() error {
 		if p == nil {
 			break
 		}
+		ol.ended[p.stream] = p.streamFlag&0b10 != 0
 		if s, ok := ol.Streams[p.stream]; !ok {
-			ol.Streams[p.stream] = Stream{serial: p.stream, pages: []*Page{p}}
+			ol.Streams[p.stream] = Stream{serial: p.stream, pages: []*Page{p}, maxPacketSize: ol.MaxPacketSize}
 		} else {
 			s.pages = append(s.pages, p)
 			ol.Streams[p.stream] = s
@@ -44,10 +98,11 @@ func (ol *OggLoader) ReadAll() error {
 }
 
 func (ol *OggLoader) readPage() (*Page, error) {
-	p := &Page{}
-	pageBytes := make([]byte, 0)
+	if ol.Lenient {
+		return ol.readPageLenient()
+	}
 
-	pattern, err := ol.GetBytes(4)
+	pattern, err := ol.getBytes(4)
 	if err != nil {
 		if errors.Is(err, io.EOF) {
 			// would be proper end of file
@@ -56,15 +111,141 @@ func (ol *OggLoader) readPage() (*Page, error) {
 		return nil, err
 	}
 	if string(pattern) != "OggS" {
-		return nil, errors.New("cannot capture page header")
+		return nil, &PageError{Offset: ol.offset() - 4, Err: ErrCapturePattern}
 	}
-	pageBytes = append(pageBytes, pattern...)
 
-	fields, err := ol.GetBytes(23)
+	_, p, err := ol.readPageBody(pattern, !ol.IgnoreChecksum)
+	return p, err
+}
+
+// readPageLenient scans for the capture pattern, and returns the first page with valid checksum.
+func (ol *OggLoader) readPageLenient() (*Page, error) {
+	skipStart := ol.offset()
+	window, err := ol.getBytes(4)
 	if err != nil {
+		if errors.Is(err, io.EOF) {
+			if len(window) > 0 {
+				ol.warn(skipStart, int64(len(window)), "skipped trailing bytes")
+			}
+			return nil, nil
+		}
 		return nil, err
 	}
-	pageBytes = append(pageBytes, fields...)
+
+	for {
+		for string(window) != "OggS" {
+			b, err := ol.getBytes(1)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					ol.warn(skipStart, ol.offset()-skipStart, "skipped trailing bytes")
+					return nil, nil
+				}
+				return nil, err
+			}
+			window = []byte{window[1], window[2], window[3], b[0]}
+		}
+		pageStart := ol.offset() - 4
+		if pageStart > skipStart {
+			ol.warn(skipStart, pageStart-skipStart, "skipped bytes without valid page")
+		}
+
+		raw, p, err := ol.readPageBody(window, true)
+		if err == nil {
+			return p, nil
+		}
+		if ol.IgnoreChecksum && errors.Is(err, ErrChecksum) {
+			followed, peekErr := ol.followedByPage()
+			if peekErr != nil {
+				return nil, peekErr
+			}
+			if followed {
+				ol.warn(pageStart, int64(len(raw)), "accepted page with wrong checksum")
+				segEnd := 27 + int(raw[26])
+				return parsePage(pageStart, raw[:4], raw[4:27], raw[27:segEnd], raw[segEnd:], false)
+			}
+		}
+		if raw == nil {
+			return nil, err
+		}
+		if len(raw) <= 4 && errors.Is(err, io.EOF) {
+			// nothing follows the pattern at the end
+			ol.warn(pageStart, ol.offset()-pageStart, "skipped trailing bytes")
+			return nil, nil
+		}
+		if errors.Is(err, io.EOF) {
+			// the length may be broken, and pages may follow in the bytes read.
+			// they are reported as skipped unless a page is found.
+			skipStart = pageStart
+		} else {
+			var pe *PageError
+			if errors.As(err, &pe) {
+				err = pe.Err
+			}
+			ol.warn(pageStart, int64(len(raw)), err.Error())
+			// bytes of the bad page are reported already
+			skipStart = pageStart + int64(len(raw))
+		}
+
+		// the candidate was not a page. scan again from the next byte of the pattern.
+		ol.pending = append(raw[5:len(raw):len(raw)], ol.pending...)
+		window = raw[1:5]
+	}
+}
+
+func (ol *OggLoader) warn(offset, length int64, reason string) {
+	if length <= 0 {
+		return
+	}
+	ol.Warnings = append(ol.Warnings, Warning{Offset: offset, Length: length, Reason: reason})
+}
+
+// readPageBody reads the rest of page after capture pattern, and verifies checksum if verify is set.
+// If the page is invalid or truncated by EOF, raw holds the bytes read as the candidate of page.
+// Errors are reported as *PageError.
+func (ol *OggLoader) readPageBody(pattern []byte, verify bool) (raw []byte, _ *Page, err error) {
+	start := ol.offset() - int64(len(pattern))
+	var serial uint32
+	pieces := [][]byte{pattern}
+	fail := func(err error) ([]byte, *Page, error) {
+		err = &PageError{Offset: start, Serial: serial, Err: err}
+		if !errors.Is(err, io.EOF) && !errors.Is(err, ErrChecksum) {
+			return nil, nil, err
+		}
+		return bytes.Join(pieces, nil), nil, err
+	}
+
+	fields, err := ol.getBytes(23)
+	pieces = append(pieces, fields)
+	if err != nil {
+		return fail(err)
+	}
+	serial = binary.LittleEndian.Uint32(fields[10:14])
+	segLens, err := ol.getBytes(int(fields[22]))
+	pieces = append(pieces, segLens)
+	if err != nil {
+		return fail(err)
+	}
+	bodyLen := 0
+	for _, sl := range segLens {
+		bodyLen += int(sl)
+	}
+	body, err := ol.getBytes(bodyLen)
+	pieces = append(pieces, body)
+	if err != nil {
+		return fail(err)
+	}
+
+	p, err := parsePage(start, pattern, fields, segLens, body, verify)
+	if err != nil {
+		return fail(err)
+	}
+	return nil, p, nil
+}
+
+// parsePage constructs a page at offset from its header fields after capture pattern, segment table and body.
+// packets refer to the memory of body. Wrong checksum is an error if verify is set, or recorded in the page otherwise.
+func parsePage(offset int64, pattern, fields, segLens, body []byte, verify bool) (*Page, error) {
+	p := &Page{offset: offset}
 
 	typeFlag := fields[1]
 	continued := typeFlag&1 == 1
@@ -75,26 +256,43 @@ func (ol *OggLoader) readPage() (*Page, error) {
 	p.seq = binary.LittleEndian.Uint32(fields[14:18])
 
 	checksum := binary.LittleEndian.Uint32(fields[18:22])
-	// fill 0 instead of checksum to verify
-	copy(pageBytes[22:26], []byte{0, 0, 0, 0})
+	// checksum is calculated over the page as read, with 0 filled in checksum field
+	calcsum := crc.Update(0, pattern)
+	calcsum = crc.Update(calcsum, fields[:18])
+	calcsum = crc.Update(calcsum, []byte{0, 0, 0, 0})
+	calcsum = crc.Update(calcsum, fields[22:])
+	calcsum = crc.Update(calcsum, segLens)
+	calcsum = crc.Update(calcsum, body)
+	if checksum != calcsum {
+		if verify {
+			return nil, fmt.Errorf("%w, read: %x <-> calc: %x", ErrChecksum, checksum, calcsum)
+		}
+		p.badChecksum = true
+	}
 
-	segListLen := int(fields[22])
+	p.segLens = segLens
+	p.body = body
+	p.splitPackets(continued)
+	return p, nil
+}
 
+// splitPackets divides the body into packets by the segment table.
+// continued tells whether the first packet continues from the previous page.
+func (p *Page) splitPackets(continued bool) {
+	segListLen := len(p.segLens)
 	p.packets = make([]Packet, 0)
+	if segListLen == 0 {
+		// empty page, such as one only to mark the end of stream
+		return
+	}
 	initPacket := Packet{}
 	if continued {
 		initPacket.continueFlag |= 1
 	}
 	p.packets = append(p.packets, initPacket)
 
-	segLens, err := ol.GetBytes(segListLen)
-	if err != nil {
-		return nil, err
-	}
-	pageBytes = append(pageBytes, segLens...)
-
 	packetIndex := 0
-	for i, sl := range segLens {
+	for i, sl := range p.segLens {
 		p.packets[packetIndex].size += uint32(sl)
 
 		if sl != 0xff && i < segListLen-1 {
@@ -107,20 +305,134 @@ func (ol *OggLoader) readPage() (*Page, error) {
 		}
 	}
 
+	ofs := uint32(0)
 	for i, packet := range p.packets {
-		data, err := ol.GetBytes(int(packet.size))
+		end := ofs + packet.size
+		// limit capacity so that appending to it never overwrites following data
+		p.packets[i].data = p.body[ofs:end:end]
+		p.packets[i].serial = p.stream
+		p.packets[i].pageOffset = p.offset
+		ofs = end
+	}
+}
+
+// NextPacket returns the next packet completed in the source, along with the serial of its stream.
+// Pages are read as needed, and io.EOF is returned after the last packet.
+// Packets are assembled in the same manner as Stream.GetPackets, except that unfinished packet at EOF is dropped.
+func (ol *OggLoader) NextPacket(ctx context.Context) (uint32, Packet, error) {
+	if ol.Follow && ol.Mapped() {
+		return 0, Packet{}, ErrNotFollowable
+	}
+	ol.ctx = ctx
+	defer func() { ol.ctx = nil }()
+	if ol.assemblers == nil {
+		ol.assemblers = map[uint32]*packetAssembler{}
+		ol.ended = map[uint32]bool{}
+	}
+
+	for len(ol.ready) == 0 {
+		if ol.Follow && ol.streamsEnded() {
+			return 0, Packet{}, io.EOF
+		}
+		p, err := ol.readPage()
 		if err != nil {
+			return 0, Packet{}, err
+		}
+		if p == nil {
+			return 0, Packet{}, io.EOF
+		}
+		pa, ok := ol.assemblers[p.stream]
+		if !ok {
+			pa = &packetAssembler{maxSize: ol.MaxPacketSize}
+			ol.assemblers[p.stream] = pa
+		}
+		ol.ended[p.stream] = p.streamFlag&0b10 != 0
+		ol.ready = pa.add(p, ol.ready[:0])
+		ol.readySerial = p.stream
+	}
+	packet := ol.ready[0]
+	ol.ready = ol.ready[1:]
+	return ol.readySerial, packet, nil
+}
+
+// streamsEnded reports whether all streams started have reached their end.
+func (ol *OggLoader) streamsEnded() bool {
+	if len(ol.ended) == 0 {
+		return false
+	}
+	for _, ended := range ol.ended {
+		if !ended {
+			return false
+		}
+	}
+	return true
+}
+
+// followedByPage reports whether the capture pattern or EOF follows, without consuming bytes.
+func (ol *OggLoader) followedByPage() (bool, error) {
+	b, err := ol.getBytes(4)
+	ol.pending = append(b[:len(b):len(b)], ol.pending...)
+	if errors.Is(err, io.EOF) {
+		return len(b) == 0, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return string(b) == "OggS", nil
+}
+
+// getBytes reads n bytes, from pushed back bytes first.
+// In follow mode, it waits for data appended at EOF.
+func (ol *OggLoader) getBytes(n int) ([]byte, error) {
+	for {
+		b, err := ol.getAvailableBytes(n)
+		if !ol.Follow || !errors.Is(err, io.EOF) {
+			return b, err
+		}
+		// keep bytes read so far, and try again after data is appended
+		ol.pending = b
+		if err := ol.wait(); err != nil {
 			return nil, err
 		}
-		p.packets[i].data = data
-		pageBytes = append(pageBytes, data...)
 	}
+}
 
-	// end of page, calculate checksum
-	calcsum := crc.CRC32(append(pageBytes, 0x0, 0x0, 0x0, 0x0), 0x0, 0x0)
-	if checksum != calcsum {
-		return nil, fmt.Errorf("checksum does not match, read: %x <-> calc: %x", checksum, calcsum)
+// wait sleeps for the poll interval, or returns error if the context is done.
+func (ol *OggLoader) wait() error {
+	ctx := ol.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	interval := ol.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	t := time.NewTimer(interval)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
 	}
+}
 
-	return p, nil
+func (ol *OggLoader) getAvailableBytes(n int) ([]byte, error) {
+	if len(ol.pending) == 0 {
+		return ol.GetBytes(n)
+	}
+	if n <= len(ol.pending) {
+		b := ol.pending[:n:n]
+		ol.pending = ol.pending[n:]
+		return b, nil
+	}
+	rest, err := ol.GetBytes(n - len(ol.pending))
+	b := append(ol.pending[:len(ol.pending):len(ol.pending)], rest...)
+	ol.pending = nil
+	return b, err
+}
+
+// offset returns the offset in source of the byte going to be read next.
+func (ol *OggLoader) offset() int64 {
+	return ol.Tell() - int64(len(ol.pending))
 }
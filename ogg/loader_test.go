@@ -0,0 +1,114 @@
+package ogg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestFollowMmap(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("mmap is not supported")
+	}
+	var ol OggLoader
+	if err := ol.OpenMmap("../testdata/test.ogg"); err != nil {
+		t.Fatal(err)
+	}
+	defer ol.Close()
+	ol.Follow = true
+	if _, _, err := ol.NextPacket(context.Background()); !errors.Is(err, ErrNotFollowable) {
+		t.Errorf("NextPacket: got %v, want ErrNotFollowable", err)
+	}
+	if err := ol.ReadAll(); !errors.Is(err, ErrNotFollowable) {
+		t.Errorf("ReadAll: got %v, want ErrNotFollowable", err)
+	}
+}
+
+// captures cut at the end of source are skipped as trailing garbage in lenient mode.
+func TestLenientTruncatedCapture(t *testing.T) {
+	data, err := os.ReadFile("../testdata/test.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tail := range []string{"O", "Og", "Ogg", "OggS", "OggS\x00", "garbageOggS", "OggSOggS"} {
+		src := append(slices.Clip(data), tail...)
+
+		ol := OggLoader{Lenient: true}
+		if err := ol.OpenReader(bytes.NewReader(src)); err != nil {
+			t.Fatal(err)
+		}
+		if err := ol.ReadAll(); err != nil {
+			t.Errorf("%q: ReadAll: %v", tail, err)
+			continue
+		}
+		if len(ol.Streams) != 1 {
+			t.Errorf("%q: got %d streams, want 1", tail, len(ol.Streams))
+		}
+		var skipped int64
+		for _, w := range ol.Warnings {
+			skipped += w.Length
+		}
+		if skipped != int64(len(tail)) {
+			t.Errorf("%q: %d bytes reported skipped, want %d: %v", tail, skipped, len(tail), ol.Warnings)
+		}
+
+		if _, err := Carve(bytes.NewReader(src)); err != nil {
+			t.Errorf("%q: Carve: %v", tail, err)
+		}
+		if _, _, err := Fix(bytes.NewReader(src), io.Discard, nil); err != nil {
+			t.Errorf("%q: Fix: %v", tail, err)
+		}
+	}
+}
+
+// a page without segments, such as one only to end the stream, has no packet.
+func TestEmptyPage(t *testing.T) {
+	pages := []Page{
+		{stream: 1, streamFlag: 0b01, granule: 0, seq: 0, segLens: []byte{3}, body: []byte("abc")},
+		{stream: 1, streamFlag: 0b10, granule: 0, seq: 1},
+	}
+	var src []byte
+	for i := range pages {
+		src = append(src, pages[i].Bytes()...)
+	}
+
+	var ol OggLoader
+	if err := ol.OpenReader(bytes.NewReader(src)); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for {
+		_, p, err := ol.NextPacket(context.Background())
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(p.Bytes()))
+	}
+	if !slices.Equal(got, []string{"abc"}) {
+		t.Errorf("NextPacket: got packets %q, want [abc]", got)
+	}
+
+	// the carved stream has the same packets
+	streams, err := Carve(bytes.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 1 {
+		t.Fatalf("carved %d streams, want 1", len(streams))
+	}
+	packets, err := streams[0].GetPackets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != 1 || string(packets[0].Bytes()) != "abc" {
+		t.Errorf("GetPackets: got %d packets, want [abc]", len(packets))
+	}
+}
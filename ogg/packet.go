@@ -17,6 +17,68 @@ type Packet struct {
 	size         uint32
 	data         []byte
 	cur          uint32
+
+	discontinuous bool
+	serial        uint32
+	pageOffset    int64 // byte offset of the page where the packet begins
+	index         int   // index of the packet in stream
+	granule       uint64
+	hasGranule    bool // the packet is the last one ending on the page with granule position
+	last          bool // the packet is the last one ending on the page with end of stream flag
+}
+
+// NewPacket returns the packet of payload data obtained elsewhere than Ogg pages.
+// data is not copied.
+func NewPacket(data []byte) Packet {
+	return Packet{size: uint32(len(data)), data: data}
+}
+
+// Serial returns the serial of the stream the packet belongs to.
+func (p *Packet) Serial() uint32 {
+	return p.serial
+}
+
+// PageOffset returns the byte offset in source of the page where the packet begins.
+func (p *Packet) PageOffset() int64 {
+	return p.pageOffset
+}
+
+// Index returns the index of the packet in stream, counting packets assembled except for lost ones.
+func (p *Packet) Index() int {
+	return p.index
+}
+
+// BitOffset returns the offset in bits of the cursor going to be read next.
+func (p *Packet) BitOffset() int {
+	return int(p.cur)
+}
+
+// BitsLeft returns the number of bits not read yet.
+func (p *Packet) BitsLeft() int {
+	return max(int(p.size)*8-int(p.cur), 0)
+}
+
+// Bytes returns the payload of the packet, which must not be modified.
+func (p *Packet) Bytes() []byte {
+	return p.data[:p.size]
+}
+
+// Granule returns the granule position of the page where the packet ends,
+// if it is the last packet ending on the page. ok is false otherwise, as in libogg.
+func (p *Packet) Granule() (granule uint64, ok bool) {
+	return p.granule, p.hasGranule
+}
+
+// EndOfStream reports whether the packet is the last one ending on the page with end of stream flag,
+// whose granule position may trim the end of the last block.
+func (p *Packet) EndOfStream() bool {
+	return p.last
+}
+
+// Discontinuous reports whether packets right before p are lost,
+// so that p cannot be overlapped with the preceding packet.
+func (p *Packet) Discontinuous() bool {
+	return p.discontinuous
 }
 
 func (p *Packet) GetUint(n uint32) (uint32, error) {
@@ -69,6 +131,9 @@ func (p *Packet) GetUintAsInt(n uint32) (int, error) {
 }
 
 func (p *Packet) GetBytes(nByte uint32) ([]byte, error) {
+	if int(nByte)*8 > p.BitsLeft() {
+		return nil, ErrEndOfPacket
+	}
 	arr := make([]byte, nByte)
 
 	for i := range arr {
@@ -0,0 +1,260 @@
+package ogg
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Change describes a modification made, or lost pages found, by Repair.
+type Change struct {
+	Serial      uint32
+	Seq         uint32 // sequence number of the page after repair
+	Description string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("stream %08x page %d: %s", c.Serial, c.Seq, c.Description)
+}
+
+// GranuleFunc returns the granule position at the end of packet, fed with packets of a stream in order.
+// ok is false if the position is unknown.
+type GranuleFunc func(p *Packet) (granule uint64, ok bool)
+
+// noGranule is the granule position of a page on which no packet ends.
+const noGranule = ^uint64(0)
+
+// Repair modifies pages of the stream to be conformant, and returns what is changed.
+// Pages are renumbered from 0 keeping gaps of sequence numbers, so that readers still detect lost pages,
+// while sequence numbers not increasing are regarded as broken and renumbered consecutively.
+// Parts of packets broken by lost pages, and continuations not matching the previous page, are dropped.
+// The beginning of stream flag is set only on the first page, the end of stream flag only on the last.
+// Checksums are recomputed when pages are written.
+// If granule is not nil, positions of pages are replaced with those of the last packets ending on them,
+// offset so that the position of the first audio page is kept, since streams may start at any position.
+// The offset is taken again after lost pages. The position of the last page is kept if it trims the end of stream.
+func (s *Stream) Repair(granule GranuleFunc) []Change {
+	if len(s.pages) == 0 {
+		return nil
+	}
+	var changes []Change
+	report := func(seq uint32, format string, args ...any) {
+		changes = append(changes, Change{Serial: s.serial, Seq: seq, Description: fmt.Sprintf(format, args...)})
+	}
+
+	// detect gaps before renumbering, and drop packets spanning them
+	seqs := make([]uint32, len(s.pages))
+	for i, page := range s.pages {
+		if i == 0 {
+			if page.continued() {
+				page.dropHead()
+				report(0, "dropped continuation of packet before the stream")
+			}
+			continue
+		}
+		prev := s.pages[i-1]
+		var missing uint32
+		if page.seq > prev.seq {
+			missing = page.seq - prev.seq - 1
+		}
+		seqs[i] = seqs[i-1] + 1 + missing
+		if missing > 0 {
+			report(seqs[i], "%d pages missing before", missing)
+		}
+		unfinished, continued := prev.unfinished(), page.continued()
+		if missing == 0 && unfinished == continued {
+			continue
+		}
+		if unfinished {
+			// the packet may span pages before, which are emptied
+			for j := i - 1; j >= 0; j-- {
+				wasContinued := s.pages[j].continued()
+				s.pages[j].dropTail()
+				report(seqs[j], "dropped unfinished packet")
+				if !wasContinued || len(s.pages[j].segLens) > 0 {
+					break
+				}
+			}
+		}
+		if continued {
+			page.dropHead()
+			report(seqs[i], "dropped continuation of lost packet")
+		}
+	}
+	if last := s.pages[len(s.pages)-1]; last.unfinished() {
+		last.dropTail()
+		report(seqs[len(seqs)-1], "dropped unfinished packet at the end")
+	}
+
+	var pa packetAssembler
+	var packets []Packet
+	prevGranule := uint64(0)
+	var offset int64 // difference of positions kept from those computed
+	synced := false
+	for i, page := range s.pages {
+		seq := seqs[i]
+
+		if page.badChecksum {
+			report(seq, "checksum recomputed")
+			page.badChecksum = false
+		}
+
+		if page.seq != seq {
+			report(seq, "sequence number %d -> %d", page.seq, seq)
+			page.seq = seq
+		}
+		if i > 0 && seq != seqs[i-1]+1 {
+			// positions after lost pages are not continuous
+			synced = false
+		}
+		packets = pa.add(page, packets[:0])
+
+		var flag byte
+		if i == 0 {
+			flag |= 1
+		}
+		if i == len(s.pages)-1 {
+			flag |= 0b10
+		}
+		if page.streamFlag != flag {
+			report(seq, "stream flags %02b -> %02b", page.streamFlag, flag)
+			page.streamFlag = flag
+		}
+
+		if granule == nil {
+			continue
+		}
+		pos, known := noGranule, true
+		for j := range packets {
+			pos, known = granule(&packets[j])
+		}
+		if !known {
+			continue
+		}
+		if pos != noGranule {
+			// headers have position 0, and the offset is taken from audio
+			if !synced && pos > 0 && page.granule != noGranule {
+				offset = int64(page.granule) - int64(pos)
+				synced = true
+			}
+			pos = uint64(int64(pos) + offset)
+		}
+		last := i == len(s.pages)-1
+		if last && len(page.segLens) == 0 {
+			// empty page at the end of stream takes the position of the last packet
+			pos = prevGranule
+		}
+		if last && pos != noGranule && page.granule < pos && page.granule >= prevGranule {
+			// the end of stream is trimmed
+			pos = page.granule
+		}
+		if page.granule != pos {
+			report(seq, "granule position %d -> %d", int64(page.granule), int64(pos))
+			page.granule = pos
+		}
+		if pos != noGranule {
+			prevGranule = pos
+		}
+	}
+	slices.SortStableFunc(changes, func(a, b Change) int {
+		return cmp.Compare(a.Seq, b.Seq)
+	})
+	return changes
+}
+
+// continued reports whether the page begins with continuation of a packet.
+func (p *Page) continued() bool {
+	return len(p.packets) > 0 && p.packets[0].continueFlag&1 != 0
+}
+
+// unfinished reports whether a packet continues beyond the page.
+func (p *Page) unfinished() bool {
+	return len(p.segLens) > 0 && p.segLens[len(p.segLens)-1] == 0xff
+}
+
+// dropHead removes the continuation of packet at the beginning of page.
+func (p *Page) dropHead() {
+	n, size := 0, 0
+	for n < len(p.segLens) {
+		sl := p.segLens[n]
+		n++
+		size += int(sl)
+		if sl != 0xff {
+			break
+		}
+	}
+	p.segLens = p.segLens[n:]
+	p.body = p.body[size:]
+	p.splitPackets(false)
+	p.clearGranule()
+}
+
+// dropTail removes the packet continuing beyond the page.
+func (p *Page) dropTail() {
+	n, size := len(p.segLens), 0
+	for n > 0 && p.segLens[n-1] == 0xff {
+		n--
+		size += 0xff
+	}
+	continued := p.continued() && n > 0
+	p.segLens = p.segLens[:n]
+	p.body = p.body[:len(p.body)-size]
+	p.splitPackets(continued)
+	p.clearGranule()
+}
+
+// clearGranule unsets the granule position if no packet ends on the page.
+func (p *Page) clearGranule() {
+	if len(p.packets) == 0 || len(p.packets) == 1 && p.unfinished() {
+		p.granule = noGranule
+	}
+}
+
+// Fix reads pages from r with a permissive parser, repairs each stream, and writes pages to w in the order read.
+// newGranule is called for each stream to create the function passed to Repair, unless nil.
+// Regions of r skipped while reading are returned as warnings.
+func Fix(r io.Reader, w io.Writer, newGranule func() GranuleFunc) ([]Change, []Warning, error) {
+	ol := OggLoader{Lenient: true, IgnoreChecksum: true}
+	err := ol.OpenReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pages []*Page
+	var streams []*Stream
+	index := map[uint32]*Stream{}
+	for {
+		p, err := ol.readPage()
+		if err != nil {
+			return nil, ol.Warnings, err
+		}
+		if p == nil {
+			break
+		}
+		pages = append(pages, p)
+		s, ok := index[p.stream]
+		if !ok {
+			s = &Stream{serial: p.stream}
+			index[p.stream] = s
+			streams = append(streams, s)
+		}
+		s.pages = append(s.pages, p)
+	}
+
+	var changes []Change
+	for _, s := range streams {
+		var granule GranuleFunc
+		if newGranule != nil {
+			granule = newGranule()
+		}
+		changes = append(changes, s.Repair(granule)...)
+	}
+
+	for _, p := range pages {
+		if _, err := w.Write(p.Bytes()); err != nil {
+			return changes, ol.Warnings, err
+		}
+	}
+	return changes, ol.Warnings, nil
+}
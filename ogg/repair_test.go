@@ -0,0 +1,113 @@
+package ogg
+
+import (
+	"bytes"
+	"testing"
+)
+
+// readStream reads the single stream in src.
+func readStream(t *testing.T, src []byte) *Stream {
+	t.Helper()
+	var ol OggLoader
+	if err := ol.OpenReader(bytes.NewReader(src)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ol.ReadAll(); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range ol.Streams {
+		return &s
+	}
+	t.Fatal("no stream")
+	return nil
+}
+
+// packetGranule returns the granule function of streams whose packets have 10 samples each.
+func packetGranule() GranuleFunc {
+	var pos uint64
+	return func(p *Packet) (uint64, bool) {
+		pos += 10
+		return pos, true
+	}
+}
+
+func TestRepairLostPages(t *testing.T) {
+	// packets of 60 bytes span pages of 100 bytes
+	s := readStream(t, writeStream(t, 1, 20, 'a'))
+	s.pages = append(s.pages[:3:3], s.pages[4:]...)
+	// sequence numbers of pages following the lost page are also broken
+	s2 := readStream(t, writeStream(t, 1, 20, 'a'))
+	s2.pages = append(s2.pages[:3:3], s2.pages[4:]...)
+	for _, p := range s2.pages[3:] {
+		p.seq--
+	}
+
+	for i, s := range []*Stream{s, s2} {
+		s.Repair(nil)
+		packets, err := s.GetPackets()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// packets broken by the lost page are dropped, rather than glued together
+		if len(packets) >= 20 {
+			t.Errorf("stream %d: got %d packets, want less than 20", i, len(packets))
+		}
+		discontinuous := 0
+		for j, p := range packets {
+			if len(p.Bytes()) != 60 {
+				t.Errorf("stream %d: packet %d has %d bytes", i, j, len(p.Bytes()))
+			}
+			if p.Discontinuous() {
+				discontinuous++
+			}
+		}
+		if i == 0 && (len(s.Gaps()) != 1 || discontinuous != 1) {
+			t.Errorf("stream %d: gaps %v and %d discontinuous packets, want 1 gap", i, s.Gaps(), discontinuous)
+		}
+	}
+}
+
+func TestRepairGranuleOffset(t *testing.T) {
+	var buf bytes.Buffer
+	pw := NewPacketWriter(&buf, 1)
+	pw.PageSize = 100
+	// 4 packets on each page, and the stream starts at position 5000
+	for i := 0; i < 40; i++ {
+		if err := pw.WritePacket(bytes.Repeat([]byte{'a'}, 30), 5000+10*uint64(i+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	src := buf.Bytes()
+
+	s := readStream(t, src)
+	if changes := s.Repair(packetGranule()); len(changes) != 0 {
+		t.Errorf("got changes %v, want none", changes)
+	}
+	var got bytes.Buffer
+	if _, err := s.WriteTo(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), src) {
+		t.Error("positions of the stream are changed")
+	}
+
+	// broken positions are recovered, and the offset is taken again after lost pages
+	s = readStream(t, src)
+	want := make([]uint64, len(s.pages))
+	for i, p := range s.pages {
+		want[i] = p.granule
+	}
+	s.pages[2].granule = 12345
+	s.pages[5].granule = 0
+	s.pages = append(s.pages[:3:3], s.pages[4:]...)
+	want = append(want[:3:3], want[4:]...)
+	s.Repair(packetGranule())
+	for i, p := range s.pages {
+		if p.granule != want[i] {
+			t.Errorf("page %d: granule %d, want %d", p.seq, p.granule, want[i])
+		}
+	}
+}
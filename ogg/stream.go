@@ -5,46 +5,139 @@ import (
 )
 
 type Stream struct {
-	serial uint32
-	pages  []*Page
+	serial        uint32
+	pages         []*Page
+	maxPacketSize int // same as OggLoader.MaxPacketSize
 }
 
+// Gap reports pages missing in a stream.
+type Gap struct {
+	Seq   uint32 // sequence number of the first missing page
+	Count uint32 // number of missing pages
+}
+
+// Gaps returns pages missing between pages of the stream.
+func (s *Stream) Gaps() []Gap {
+	var gaps []Gap
+	for i := 1; i < len(s.pages); i++ {
+		expected := s.pages[i-1].seq + 1
+		if s.pages[i].seq != expected {
+			gaps = append(gaps, Gap{Seq: expected, Count: s.pages[i].seq - expected})
+		}
+	}
+	return gaps
+}
+
+// LastGranule returns the granule position of the last page on which a packet ends,
+// which is the end of the stream. ok is false if there is no such page.
+func (s *Stream) LastGranule() (granule uint64, ok bool) {
+	for i := len(s.pages) - 1; i >= 0; i-- {
+		if s.pages[i].granule != noGranule {
+			return s.pages[i].granule, true
+		}
+	}
+	return 0, false
+}
+
+// GetPackets assembles packets from pages of the stream.
+// The stream may start at arbitrary page sequence, and may lack pages.
+// Packets spanning missing pages are dropped, and the packet following them is marked as discontinuous.
+// If the last page has no end-of-stream flag, the stream is regarded as cut, and the unfinished packet is dropped.
+// Packets larger than OggLoader.MaxPacketSize of the loader are dropped as lost.
 func (s *Stream) GetPackets() ([]Packet, error) {
 	if len(s.pages) == 0 {
 		return nil, errors.New("no pages in stream")
 	}
-	if s.pages[0].streamFlag&1 == 0 {
+	if s.pages[0].seq == 0 && s.pages[0].streamFlag&1 == 0 {
 		return nil, errors.New("invalid stream beginning")
 	}
-	if s.pages[len(s.pages)-1].streamFlag&0b10 == 0 {
-		return nil, errors.New("invalid stream end")
-	}
 	packetList := make([]Packet, 0)
-	var tmp Packet
-	for i, page := range s.pages {
-		if page.seq != uint32(i) {
-			return nil, errors.New("invalid page sequence")
-		}
-		for _, packet := range page.packets {
-			pre := tmp.continueFlag&0b10 != 0
-			suf := packet.continueFlag&1 != 0
-
-			if pre && suf {
-				tmp.data = append(tmp.data, packet.data...)
-				tmp.continueFlag = packet.continueFlag
-				tmp.size += packet.size
-			} else if !pre && !suf {
-				tmp = packet
-			} else {
-				return nil, errors.New("packet continuation mismatch")
+	pa := packetAssembler{maxSize: s.maxPacketSize}
+	for _, page := range s.pages {
+		packetList = pa.add(page, packetList)
+	}
+	if pa.unfinished() && s.pages[len(s.pages)-1].streamFlag&0b10 != 0 {
+		return nil, errors.New("unfinished packet at the end")
+	}
+	return packetList, nil
+}
+
+// packetAssembler builds packets from pages of a stream in order.
+type packetAssembler struct {
+	maxSize int // same as OggLoader.MaxPacketSize
+	count   int // number of packets assembled
+	tmp     Packet
+	lost    bool
+	started bool
+	lastSeq uint32
+}
+
+// add appends packets completed by page to packets.
+func (pa *packetAssembler) add(page *Page, packets []Packet) []Packet {
+	if pa.started && page.seq != pa.lastSeq+1 {
+		// unfinished packet spans the gap
+		pa.tmp = Packet{}
+		pa.lost = true
+	}
+	first := !pa.started
+	pa.started = true
+	pa.lastSeq = page.seq
+	done := len(packets)
+
+	for _, packet := range page.packets {
+		pre := pa.tmp.continueFlag&0b10 != 0
+		suf := packet.continueFlag&1 != 0
+
+		if pre && suf {
+			if pa.exceeds(int(pa.tmp.size) + int(packet.size)) {
+				// the rest of packet is dropped as lost
+				pa.tmp = Packet{}
+				pa.lost = true
+				continue
 			}
-			if tmp.continueFlag&0b10 == 0 {
-				packetList = append(packetList, tmp)
+			pa.tmp.data = append(pa.tmp.data, packet.data...)
+			pa.tmp.continueFlag = packet.continueFlag
+			pa.tmp.size += packet.size
+		} else if !pre && !suf {
+			pa.tmp = packet
+		} else if pre { // the rest of packet is lost
+			pa.tmp = packet
+			pa.lost = true
+		} else { // the head of packet is lost
+			pa.tmp = Packet{}
+			if !first { // not the cut at the beginning
+				pa.lost = true
 			}
+			continue
+		}
+		if pa.tmp.continueFlag&0b10 == 0 {
+			pa.tmp.discontinuous = pa.lost
+			pa.tmp.index = pa.count
+			pa.count++
+			pa.lost = false
+			packets = append(packets, pa.tmp)
 		}
 	}
-	if tmp.continueFlag&0b10 != 0 {
-		return nil, errors.New("unfinished packet at the end")
+	if len(packets) > done && page.granule != noGranule {
+		packets[len(packets)-1].granule = page.granule
+		packets[len(packets)-1].hasGranule = true
 	}
-	return packetList, nil
+	if len(packets) > done && page.streamFlag&0b10 != 0 {
+		packets[len(packets)-1].last = true
+	}
+	return packets
+}
+
+// exceeds reports whether a packet of size exceeds the limit.
+func (pa *packetAssembler) exceeds(size int) bool {
+	limit := pa.maxSize
+	if limit == 0 {
+		limit = DefaultMaxPacketSize
+	}
+	return limit > 0 && size > limit
+}
+
+// unfinished reports whether a packet continues beyond the last page added.
+func (pa *packetAssembler) unfinished() bool {
+	return pa.tmp.continueFlag&0b10 != 0
 }
@@ -0,0 +1,157 @@
+package ogg
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/sr8e/vorbis/crc"
+)
+
+// Bytes returns the page encoded in the format read, with checksum computed.
+func (p *Page) Bytes() []byte {
+	b := make([]byte, 27+len(p.segLens)+len(p.body))
+	copy(b, "OggS")
+	// b[4] is stream structure version, always 0
+	typeFlag := p.streamFlag << 1
+	if len(p.packets) > 0 && p.packets[0].continueFlag&1 != 0 {
+		typeFlag |= 1
+	}
+	b[5] = typeFlag
+	binary.LittleEndian.PutUint64(b[6:14], p.granule)
+	binary.LittleEndian.PutUint32(b[14:18], p.stream)
+	binary.LittleEndian.PutUint32(b[18:22], p.seq)
+	b[26] = byte(len(p.segLens))
+	copy(b[27:], p.segLens)
+	copy(b[27+len(p.segLens):], p.body)
+
+	// checksum field is filled with 0 during computation
+	binary.LittleEndian.PutUint32(b[22:26], crc.Update(0, b))
+	return b
+}
+
+// Serial returns the serial number of the stream.
+func (s *Stream) Serial() uint32 {
+	return s.serial
+}
+
+// NumPages returns the number of pages in the stream.
+func (s *Stream) NumPages() int {
+	return len(s.pages)
+}
+
+// WriteTo writes pages of the stream to w in order.
+func (s *Stream) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for _, p := range s.pages {
+		n, err := w.Write(p.Bytes())
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// DefaultPageSize is the size of page body PacketWriter fills before writing the page out.
+const DefaultPageSize = 4096
+
+// PacketWriter writes packets of a logical stream into pages.
+type PacketWriter struct {
+	w      io.Writer
+	serial uint32
+	seq    uint32
+
+	// PageSize is the size of page body, beyond which the page is written out. DefaultPageSize is used if zero.
+	PageSize int
+
+	// the page being filled
+	segLens   []byte
+	body      []byte
+	granule   uint64 // position of the last packet written
+	continued bool   // the page begins with continuation of a packet
+	ended     bool   // a packet ends on the page
+}
+
+// NewPacketWriter returns the writer of stream of serial into w.
+func NewPacketWriter(w io.Writer, serial uint32) *PacketWriter {
+	return &PacketWriter{w: w, serial: serial}
+}
+
+// WritePacket appends a packet whose granule position is granule,
+// and writes out pages filled with it.
+func (pw *PacketWriter) WritePacket(data []byte, granule uint64) error {
+	for first := true; ; first = false {
+		if len(pw.segLens) == 255 {
+			if err := pw.writePage(false); err != nil {
+				return err
+			}
+			// the previous page may end exactly at the end of a packet
+			pw.continued = !first
+		}
+		n := min(len(data), 255)
+		pw.segLens = append(pw.segLens, byte(n))
+		pw.body = append(pw.body, data[:n]...)
+		data = data[n:]
+		// packet of multiple of 255 bytes is terminated by a segment of 0
+		if n < 255 {
+			break
+		}
+	}
+	pw.granule = granule
+	pw.ended = true
+
+	pageSize := pw.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+	if len(pw.body) >= pageSize {
+		return pw.Flush()
+	}
+	return nil
+}
+
+// Flush writes out the packets appended so far, so that the next packet begins a new page.
+func (pw *PacketWriter) Flush() error {
+	if len(pw.segLens) == 0 {
+		return nil
+	}
+	return pw.writePage(false)
+}
+
+// Close writes out the last page with the end of stream flag.
+// It does not close the underlying writer.
+func (pw *PacketWriter) Close() error {
+	return pw.writePage(true)
+}
+
+func (pw *PacketWriter) writePage(eos bool) error {
+	p := Page{
+		stream:  pw.serial,
+		granule: noGranule,
+		seq:     pw.seq,
+		segLens: pw.segLens,
+		body:    pw.body,
+	}
+	if pw.seq == 0 {
+		p.streamFlag |= 0b01
+	}
+	if eos {
+		p.streamFlag |= 0b10
+	}
+	// empty page at the end of stream takes the position of the last packet
+	if pw.ended || (eos && len(pw.segLens) == 0) {
+		p.granule = pw.granule
+	}
+	if pw.continued {
+		p.packets = []Packet{{continueFlag: 1}}
+	}
+	if _, err := pw.w.Write(p.Bytes()); err != nil {
+		return err
+	}
+	pw.seq++
+	pw.segLens = pw.segLens[:0]
+	pw.body = pw.body[:0]
+	pw.continued = false
+	pw.ended = false
+	return nil
+}
@@ -0,0 +1,137 @@
+package ogg
+
+import (
+	"bytes"
+	"testing"
+)
+
+// readPackets reads packets of the single stream in src.
+func readPackets(t *testing.T, src []byte) []Packet {
+	t.Helper()
+	var ol OggLoader
+	if err := ol.OpenReader(bytes.NewReader(src)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ol.ReadAll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ol.Streams) != 1 {
+		t.Fatalf("got %d streams, want 1", len(ol.Streams))
+	}
+	for _, s := range ol.Streams {
+		packets, err := s.GetPackets()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return packets
+	}
+	return nil
+}
+
+func TestPacketWriterRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		sizes []int
+	}{
+		// the 255th segment ends a packet, so the next page begins a new one
+		{"segment boundary", repeat(10, 300)},
+		{"long packets", repeat(255*3+7, 5)},
+		{"multiple of 255", repeat(255*2, 200)},
+		// the last page has no segments, only to end the stream
+		{"empty last page", repeat(DefaultPageSize, 3)},
+		{"mixed", []int{0, 1, 254, 255, 256, 65025, 0, 70000, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			pw := NewPacketWriter(&buf, 1234)
+			var want [][]byte
+			for i, size := range tt.sizes {
+				data := bytes.Repeat([]byte{byte(i)}, size)
+				want = append(want, data)
+				if err := pw.WritePacket(data, uint64(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := pw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			got := readPackets(t, buf.Bytes())
+			if len(got) != len(want) {
+				t.Fatalf("got %d packets, want %d", len(got), len(want))
+			}
+			for i := range got {
+				if !bytes.Equal(got[i].Bytes(), want[i]) {
+					t.Errorf("packet %d: got %d bytes, want %d", i, len(got[i].Bytes()), len(want[i]))
+				}
+			}
+		})
+	}
+}
+
+func repeat(size, count int) []int {
+	sizes := make([]int, count)
+	for i := range sizes {
+		sizes[i] = size
+	}
+	return sizes
+}
+
+func TestPacketGranule(t *testing.T) {
+	var buf bytes.Buffer
+	pw := NewPacketWriter(&buf, 1)
+	pw.PageSize = 100
+	// 4 packets on each page
+	for i := 0; i < 10; i++ {
+		if err := pw.WritePacket(make([]byte, 30), uint64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range readPackets(t, buf.Bytes()) {
+		granule, ok := p.Granule()
+		if last := i%4 == 3 || i == 9; ok != last || ok && granule != uint64(i) {
+			t.Errorf("packet %d: got granule %d, %t", i, granule, ok)
+		}
+		if p.EndOfStream() != (i == 9) {
+			t.Errorf("packet %d: end of stream is %t", i, p.EndOfStream())
+		}
+	}
+}
+
+// the page after one filled with 255 segments is flagged continued only if a packet spans them.
+func TestPacketWriterContinuedFlag(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		sizes     []int
+		continued bool
+	}{
+		{"packet ends on the full page", repeat(10, 256), false},
+		{"packet spans the full page", []int{255 * 300}, true},
+	} {
+		var buf bytes.Buffer
+		pw := NewPacketWriter(&buf, 1)
+		for i, size := range tt.sizes {
+			if err := pw.WritePacket(make([]byte, size), uint64(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := pw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		b := buf.Bytes()
+		if b[26] != 255 {
+			t.Fatalf("%s: the first page has %d segments, want 255", tt.name, b[26])
+		}
+		second := 27 + 255
+		for _, sl := range b[27:second] {
+			second += int(sl)
+		}
+		if got := b[second+5]&1 != 0; got != tt.continued {
+			t.Errorf("%s: the second page is flagged continued %t, want %t", tt.name, got, tt.continued)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package opus reads Opus streams in Ogg (RFC 7845), and splits their packets into frames (RFC 6716).
+// It does not decode audio, which is left to a codec fed with the frames.
+package opus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotOpus is returned when the stream is not Opus.
+var ErrNotOpus = errors.New("not an Opus stream")
+
+// SampleRate is the rate of granule positions and decoded samples, regardless of the input sample rate.
+const SampleRate = 48000
+
+// Head is the identification header, OpusHead.
+type Head struct {
+	Version  uint8
+	Channels uint8
+	// PreSkip is the number of samples at 48 kHz to be discarded from the beginning of decoded output.
+	PreSkip uint16
+	// InputSampleRate is the sample rate of the original input, for information only.
+	InputSampleRate uint32
+	// OutputGain is the gain to be applied to decoded output in dB, in Q7.8 fixed point.
+	OutputGain int16
+	// MappingFamily is 0 for mono or stereo, 1 for Vorbis channel order of up to 8 channels, 255 for undefined.
+	MappingFamily uint8
+	StreamCount   uint8
+	CoupledCount  uint8   // number of streams coded in stereo, which come first
+	Mapping       []uint8 // decoded channel of each output channel, 255 for silence
+}
+
+// Tags is the comment header, OpusTags.
+type Tags struct {
+	Vendor   string
+	Comments []string // "NAME=value" pairs, as is
+}
+
+// ParseHead parses the identification header packet.
+func ParseHead(data []byte) (Head, error) {
+	if len(data) < 19 || string(data[:8]) != "OpusHead" {
+		return Head{}, fmt.Errorf("%w: invalid identification header", ErrNotOpus)
+	}
+	h := Head{
+		Version:         data[8],
+		Channels:        data[9],
+		PreSkip:         binary.LittleEndian.Uint16(data[10:12]),
+		InputSampleRate: binary.LittleEndian.Uint32(data[12:16]),
+		OutputGain:      int16(binary.LittleEndian.Uint16(data[16:18])),
+		MappingFamily:   data[18],
+	}
+	// minor versions are compatible
+	if h.Version>>4 != 0 {
+		return Head{}, fmt.Errorf("incompatible version %d", h.Version)
+	}
+	if h.Channels == 0 {
+		return Head{}, errors.New("channel count is zero")
+	}
+
+	if h.MappingFamily == 0 {
+		if h.Channels > 2 {
+			return Head{}, fmt.Errorf("mapping family 0 cannot have %d channels", h.Channels)
+		}
+		h.StreamCount = 1
+		h.CoupledCount = h.Channels - 1
+		h.Mapping = []uint8{0, 1}[:h.Channels]
+		return h, nil
+	}
+
+	if len(data) < 21+int(h.Channels) {
+		return Head{}, errors.New("channel mapping table is truncated")
+	}
+	h.StreamCount = data[19]
+	h.CoupledCount = data[20]
+	h.Mapping = append([]uint8(nil), data[21:21+int(h.Channels)]...)
+	if h.StreamCount == 0 || h.CoupledCount > h.StreamCount || int(h.StreamCount)+int(h.CoupledCount) > 255 {
+		return Head{}, fmt.Errorf("invalid stream count %d with %d coupled", h.StreamCount, h.CoupledCount)
+	}
+	if h.MappingFamily == 1 && h.Channels > 8 {
+		return Head{}, fmt.Errorf("mapping family 1 cannot have %d channels", h.Channels)
+	}
+	decoded := int(h.StreamCount) + int(h.CoupledCount)
+	for _, m := range h.Mapping {
+		if m != 255 && int(m) >= decoded {
+			return Head{}, fmt.Errorf("channel mapped to undefined channel %d", m)
+		}
+	}
+	return h, nil
+}
+
+// ParseTags parses the comment header packet.
+func ParseTags(data []byte) (Tags, error) {
+	if len(data) < 8 || string(data[:8]) != "OpusTags" {
+		return Tags{}, fmt.Errorf("%w: invalid comment header", ErrNotOpus)
+	}
+	data = data[8:]
+	readString := func() (string, error) {
+		if len(data) < 4 {
+			return "", errors.New("comment header is truncated")
+		}
+		l := binary.LittleEndian.Uint32(data)
+		if uint64(l) > uint64(len(data)-4) {
+			return "", errors.New("comment header is truncated")
+		}
+		s := string(data[4 : 4+l])
+		data = data[4+l:]
+		return s, nil
+	}
+	vendor, err := readString()
+	if err != nil {
+		return Tags{}, err
+	}
+	if len(data) < 4 {
+		return Tags{}, errors.New("comment header is truncated")
+	}
+	n := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	// each comment takes 4 bytes at least
+	if uint64(n) > uint64(len(data)/4) {
+		return Tags{}, fmt.Errorf("too many comments: %d", n)
+	}
+	comments := make([]string, n)
+	for i := range comments {
+		comments[i], err = readString()
+		if err != nil {
+			return Tags{}, err
+		}
+	}
+	// binary data may follow, which is ignored
+	return Tags{Vendor: vendor, Comments: comments}, nil
+}
+
+// Time returns the playback time of granule position, excluding pre-skip, for streams starting at 0.
+// Stream.Time accounts for streams starting elsewhere.
+func (h *Head) Time(granule uint64) time.Duration {
+	if granule <= uint64(h.PreSkip) {
+		return 0
+	}
+	return SamplesToTime(int64(granule - uint64(h.PreSkip)))
+}
+
+// SamplesToTime converts the number of samples at 48 kHz into duration.
+func SamplesToTime(n int64) time.Duration {
+	// split to avoid overflow of long streams
+	return time.Duration(n/SampleRate)*time.Second + time.Duration(n%SampleRate*int64(time.Second)/SampleRate)
+}
@@ -0,0 +1,127 @@
+package opus
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+// Stream is an Opus logical stream read from Ogg.
+type Stream struct {
+	Serial  uint32
+	Head    Head
+	Tags    Tags
+	Packets []ogg.Packet // audio packets
+	// Start is the granule position at the beginning of the stream, which is not 0 for streams
+	// cut from others (RFC 7845, section 4). It is derived from the granule position of the first audio page.
+	Start uint64
+
+	granule    uint64 // of the last page
+	hasGranule bool
+}
+
+// NewStream reads Ogg bitstream from r, and returns the first Opus logical stream in it.
+func NewStream(r io.Reader) (*Stream, error) {
+	var ol ogg.OggLoader
+	err := ol.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	err = ol.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	serials := make([]uint32, 0, len(ol.Streams))
+	for serial := range ol.Streams {
+		serials = append(serials, serial)
+	}
+	slices.Sort(serials)
+
+	err = ErrNotOpus
+	for _, serial := range serials {
+		s := ol.Streams[serial]
+		packets, streamErr := s.GetPackets()
+		if streamErr != nil {
+			err = streamErr
+			continue
+		}
+		if len(packets) < 2 || !bytes.HasPrefix(packets[0].Bytes(), []byte("OpusHead")) {
+			continue
+		}
+		return newStream(&s, packets)
+	}
+	return nil, err
+}
+
+func newStream(s *ogg.Stream, packets []ogg.Packet) (*Stream, error) {
+	head, err := ParseHead(packets[0].Bytes())
+	if err != nil {
+		return nil, err
+	}
+	tags, err := ParseTags(packets[1].Bytes())
+	if err != nil {
+		return nil, err
+	}
+	granule, ok := s.LastGranule()
+	st := &Stream{
+		Serial:     s.Serial(),
+		Head:       head,
+		Tags:       tags,
+		Packets:    packets[2:],
+		granule:    granule,
+		hasGranule: ok,
+	}
+	st.Start = startGranule(st.Packets)
+	return st, nil
+}
+
+// startGranule returns the granule position at the beginning of audio packets,
+// which is the position of the first page where a packet ends minus the duration of packets up to there.
+// A position less than the duration is the end trimmed, and the stream starts at 0.
+func startGranule(packets []ogg.Packet) uint64 {
+	total := 0
+	for i := range packets {
+		n, err := PacketDuration(packets[i].Bytes())
+		if err != nil {
+			return 0
+		}
+		total += n
+		if granule, ok := packets[i].Granule(); ok {
+			if granule < uint64(total) {
+				return 0
+			}
+			return granule - uint64(total)
+		}
+	}
+	return 0
+}
+
+// Time returns the playback time of granule position of the stream, excluding pre-skip.
+func (s *Stream) Time(granule uint64) time.Duration {
+	if granule <= s.Start {
+		return 0
+	}
+	return s.Head.Time(granule - s.Start)
+}
+
+// Duration returns the playback time of the stream excluding pre-skip, from the granule position of the last page.
+// If no page has granule position, it is computed from durations of packets.
+func (s *Stream) Duration() (time.Duration, error) {
+	if s.hasGranule {
+		return s.Time(s.granule), nil
+	}
+	total := 0
+	for i := range s.Packets {
+		n, err := PacketDuration(s.Packets[i].Bytes())
+		if err != nil {
+			return 0, fmt.Errorf("packet %d: %w", s.Packets[i].Index(), err)
+		}
+		total += n
+	}
+	return s.Head.Time(uint64(total)), nil
+}
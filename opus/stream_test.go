@@ -0,0 +1,64 @@
+package opus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+// writeStream returns an Opus stream of count packets of 20 ms, whose granule position starts at start.
+func writeStream(t *testing.T, start uint64, count int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	pw := ogg.NewPacketWriter(&buf, 1)
+	head := append([]byte("OpusHead"), 1, 1)
+	head = binary.LittleEndian.AppendUint16(head, 312)
+	head = binary.LittleEndian.AppendUint32(head, 48000)
+	head = append(head, 0, 0, 0)
+	tags := append([]byte("OpusTags"), 0, 0, 0, 0, 0, 0, 0, 0)
+	for _, h := range [][]byte{head, tags} {
+		if err := pw.WritePacket(h, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := pw.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pw.PageSize = 20
+	for i := 0; i < count; i++ {
+		// CELT fullband 20 ms, a frame of silence
+		if err := pw.WritePacket([]byte{31 << 3, 0, 0, 0}, start+960*uint64(i+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestStreamDuration(t *testing.T) {
+	want := SamplesToTime(960*50 - 312)
+	for _, start := range []uint64{0, 48000 * 3600} {
+		s, err := NewStream(bytes.NewReader(writeStream(t, start, 50)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Start != start {
+			t.Errorf("start %d: got start %d", start, s.Start)
+		}
+		got, err := s.Duration()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("start %d: got duration %v, want %v", start, got, want)
+		}
+		if got := s.Time(start + 48000); got != time.Second-SamplesToTime(312) {
+			t.Errorf("start %d: got time %v", start, got)
+		}
+	}
+}
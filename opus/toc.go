@@ -0,0 +1,318 @@
+package opus
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Mode is the coding mode of Opus frames.
+type Mode int
+
+const (
+	ModeSILK Mode = iota
+	ModeHybrid
+	ModeCELT
+)
+
+func (m Mode) String() string {
+	return [...]string{"SILK", "hybrid", "CELT"}[m]
+}
+
+// Bandwidth is the audio bandwidth of Opus frames.
+type Bandwidth int
+
+const (
+	Narrowband    Bandwidth = iota // 4 kHz
+	Mediumband                     // 6 kHz
+	Wideband                       // 8 kHz
+	SuperWideband                  // 12 kHz
+	Fullband                       // 20 kHz
+)
+
+func (b Bandwidth) String() string {
+	return [...]string{"NB", "MB", "WB", "SWB", "FB"}[b]
+}
+
+// TOC is the table-of-contents byte at the beginning of Opus packet.
+type TOC byte
+
+// Config returns the configuration number, which determines mode, bandwidth and frame size.
+func (t TOC) Config() int {
+	return int(t >> 3)
+}
+
+// Stereo reports whether frames are coded in stereo.
+func (t TOC) Stereo() bool {
+	return t&0b100 != 0
+}
+
+// Code returns the code of the number of frames in the packet.
+func (t TOC) Code() int {
+	return int(t & 0b11)
+}
+
+func (t TOC) Mode() Mode {
+	switch c := t.Config(); {
+	case c < 12:
+		return ModeSILK
+	case c < 16:
+		return ModeHybrid
+	}
+	return ModeCELT
+}
+
+func (t TOC) Bandwidth() Bandwidth {
+	switch c := t.Config(); {
+	case c < 12:
+		return Bandwidth(c / 4) // NB, MB, WB
+	case c < 16:
+		return SuperWideband + Bandwidth((c-12)/2)
+	case c < 20:
+		return Narrowband
+	}
+	// CELT has no mediumband
+	return Wideband + Bandwidth((t.Config()-20)/4)
+}
+
+// FrameSize returns the number of samples at 48 kHz in a frame.
+func (t TOC) FrameSize() int {
+	c := t.Config()
+	switch {
+	case c < 12: // 10, 20, 40, 60 ms
+		return [...]int{480, 960, 1920, 2880}[c%4]
+	case c < 16: // 10, 20 ms
+		return [...]int{480, 960}[c%2]
+	}
+	// 2.5, 5, 10, 20 ms
+	return [...]int{120, 240, 480, 960}[c%4]
+}
+
+// maxFrameLen is the maximum length of a frame in bytes.
+const maxFrameLen = 1275
+
+// maxPacketDuration is the maximum duration of a packet in samples at 48 kHz, 120 ms.
+const maxPacketDuration = 5760
+
+// Packet is an Opus packet split into frames.
+type Packet struct {
+	TOC     TOC
+	Frames  [][]byte // shares memory with the packet data
+	Padding int      // length of padding in bytes
+}
+
+// Duration returns the number of samples at 48 kHz decoded from the packet.
+func (p *Packet) Duration() int {
+	return len(p.Frames) * p.TOC.FrameSize()
+}
+
+// ParsePacket splits a packet into frames, validating its framing (RFC 6716 Section 3.4).
+func ParsePacket(data []byte) (Packet, error) {
+	p, _, err := parsePacket(data, false)
+	return p, err
+}
+
+// ParseMultistream splits a packet of a multistream stream into packets of its streams,
+// all of which but the last are self-delimited (RFC 6716 Appendix B). streams is Head.StreamCount.
+func ParseMultistream(data []byte, streams int) ([]Packet, error) {
+	if streams < 1 {
+		return nil, fmt.Errorf("invalid stream count %d", streams)
+	}
+	packets := make([]Packet, streams)
+	for i := range packets {
+		p, n, err := parsePacket(data, i < streams-1)
+		if err != nil {
+			return nil, fmt.Errorf("stream %d: %w", i, err)
+		}
+		if i > 0 && p.Duration() != packets[0].Duration() {
+			return nil, fmt.Errorf("stream %d: duration %d differs from %d", i, p.Duration(), packets[0].Duration())
+		}
+		packets[i] = p
+		data = data[n:]
+	}
+	return packets, nil
+}
+
+// parsePacket splits a packet into frames and returns it with the bytes used.
+// A self-delimited packet, which precedes the last one in a multistream packet (RFC 6716 Appendix B),
+// codes the length of its last frame, and may be followed by other data.
+func parsePacket(data []byte, selfDelimited bool) (Packet, int, error) {
+	if len(data) == 0 {
+		return Packet{}, 0, errors.New("empty packet")
+	}
+	p := Packet{TOC: TOC(data[0])}
+	total := len(data)
+	data = data[1:]
+
+	// explicit reads a frame length in self-delimited packets
+	explicit := func() (int, error) {
+		n, l, err := frameLength(data)
+		data = data[l:]
+		return n, err
+	}
+	var sizes []int
+	switch p.TOC.Code() {
+	case 0: // one frame
+		n := len(data)
+		if selfDelimited {
+			var err error
+			if n, err = explicit(); err != nil {
+				return Packet{}, 0, err
+			}
+		}
+		sizes = []int{n}
+	case 1: // two frames of the same size
+		var n int
+		if selfDelimited {
+			var err error
+			if n, err = explicit(); err != nil {
+				return Packet{}, 0, err
+			}
+		} else {
+			if len(data)%2 != 0 {
+				return Packet{}, 0, errors.New("odd size of two frames of the same size")
+			}
+			n = len(data) / 2
+		}
+		sizes = []int{n, n}
+	case 2: // two frames of different sizes
+		n, err := explicit()
+		if err != nil {
+			return Packet{}, 0, err
+		}
+		m := len(data) - n
+		if selfDelimited {
+			if m, err = explicit(); err != nil {
+				return Packet{}, 0, err
+			}
+		}
+		if n > len(data) {
+			return Packet{}, 0, errors.New("frame exceeds packet")
+		}
+		sizes = []int{n, m}
+	case 3: // arbitrary number of frames
+		if len(data) == 0 {
+			return Packet{}, 0, errors.New("frame count byte is missing")
+		}
+		count := int(data[0] & 0x3f)
+		vbr := data[0]&0x80 != 0
+		padded := data[0]&0x40 != 0
+		data = data[1:]
+		if count == 0 || count*p.TOC.FrameSize() > maxPacketDuration {
+			return Packet{}, 0, fmt.Errorf("invalid frame count %d", count)
+		}
+		if padded {
+			for {
+				if len(data) == 0 {
+					return Packet{}, 0, errors.New("padding length is truncated")
+				}
+				v := int(data[0])
+				data = data[1:]
+				if v == 255 {
+					p.Padding += 254
+					continue
+				}
+				p.Padding += v
+				break
+			}
+			if p.Padding > len(data) {
+				return Packet{}, 0, errors.New("padding exceeds packet")
+			}
+		}
+		sizes = make([]int, count)
+		// lengths coded: all but the last for VBR, and one more if self-delimited
+		coded := 0
+		if vbr {
+			coded = count - 1
+		}
+		if selfDelimited {
+			coded++
+		}
+		sum := 0
+		for i := 0; i < coded; i++ {
+			n, err := explicit()
+			if err != nil {
+				return Packet{}, 0, err
+			}
+			sizes[i] = n
+			sum += n
+		}
+		switch {
+		case vbr && selfDelimited:
+			// every length is coded
+		case vbr:
+			body := len(data) - p.Padding
+			if body < sum {
+				return Packet{}, 0, errors.New("frames exceed packet")
+			}
+			sizes[count-1] = body - sum
+		case selfDelimited:
+			for i := range sizes {
+				sizes[i] = sizes[0]
+			}
+		default:
+			body := len(data) - p.Padding
+			if body < 0 || body%count != 0 {
+				return Packet{}, 0, errors.New("size of frames of the same size is not divisible")
+			}
+			for i := range sizes {
+				sizes[i] = body / count
+			}
+		}
+	}
+
+	p.Frames = make([][]byte, len(sizes))
+	for i, n := range sizes {
+		if n > len(data) {
+			return Packet{}, 0, errors.New("frames exceed packet")
+		}
+		if n > maxFrameLen {
+			return Packet{}, 0, fmt.Errorf("frame of %d bytes exceeds the maximum", n)
+		}
+		p.Frames[i] = data[:n:n]
+		data = data[n:]
+	}
+	if p.Padding > len(data) {
+		return Packet{}, 0, errors.New("padding exceeds packet")
+	}
+	data = data[p.Padding:]
+	if !selfDelimited && len(data) != 0 {
+		return Packet{}, 0, errors.New("extra data after frames")
+	}
+	return p, total - len(data), nil
+}
+
+// frameLength reads the length of frame coded in one or two bytes, and returns it with the bytes used.
+func frameLength(data []byte) (n, l int, err error) {
+	if len(data) == 0 {
+		return 0, 0, errors.New("frame length is truncated")
+	}
+	if data[0] < 252 {
+		return int(data[0]), 1, nil
+	}
+	if len(data) < 2 {
+		return 0, 0, errors.New("frame length is truncated")
+	}
+	return int(data[1])*4 + int(data[0]), 2, nil
+}
+
+// PacketDuration returns the number of samples at 48 kHz decoded from a packet, reading only its header.
+func PacketDuration(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, errors.New("empty packet")
+	}
+	toc := TOC(data[0])
+	switch toc.Code() {
+	case 0:
+		return toc.FrameSize(), nil
+	case 1, 2:
+		return 2 * toc.FrameSize(), nil
+	}
+	if len(data) < 2 {
+		return 0, errors.New("frame count byte is missing")
+	}
+	n := int(data[1]&0x3f) * toc.FrameSize()
+	if n == 0 || n > maxPacketDuration {
+		return 0, fmt.Errorf("invalid frame count %d", data[1]&0x3f)
+	}
+	return n, nil
+}
@@ -0,0 +1,56 @@
+package opus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseMultistream(t *testing.T) {
+	// CELT fullband 20 ms
+	const toc = 31 << 3
+	a, b, c := bytes.Repeat([]byte{1}, 3), bytes.Repeat([]byte{2}, 300), bytes.Repeat([]byte{3}, 5)
+	cat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	tests := []struct {
+		name   string
+		data   []byte
+		frames [][][]byte
+	}{
+		// self-delimited code 0 codes the length of its frame
+		{"code 0", cat([]byte{toc, 3}, a, []byte{toc}, c), [][][]byte{{a}, {c}}},
+		// length of 300 takes two bytes
+		{"code 1", cat([]byte{toc | 1, 252, 12}, b, b, []byte{toc | 1}, c, c), [][][]byte{{b, b}, {c, c}}},
+		// VBR code 3 codes every length when self-delimited
+		{"code 3", cat([]byte{toc | 3, 0x80 | 2, 3, 5}, a, c, []byte{toc | 2, 3}, a), [][][]byte{{a, c}, {a, {}}}},
+	}
+
+	for _, tt := range tests {
+		packets, err := ParseMultistream(tt.data, 2)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		for i, p := range packets {
+			if len(p.Frames) != len(tt.frames[i]) {
+				t.Errorf("%s: stream %d: got %d frames, want %d", tt.name, i, len(p.Frames), len(tt.frames[i]))
+				continue
+			}
+			for j, f := range p.Frames {
+				if !bytes.Equal(f, tt.frames[i][j]) {
+					t.Errorf("%s: stream %d: frame %d differs", tt.name, i, j)
+				}
+			}
+		}
+	}
+
+	// streams of different durations, and data missing for the last stream
+	for _, data := range [][]byte{
+		cat([]byte{toc, 3}, a, []byte{toc | 1}, a[:2]),
+		cat([]byte{toc, 3}, a),
+	} {
+		if _, err := ParseMultistream(data, 2); err == nil {
+			t.Errorf("%x: no error", data)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/sr8e/vorbis/crc"
+)
+
+// Config is a configuration of Vorbis stream, the identification, comment and setup header.
+type Config struct {
+	Ident   uint32
+	Headers [][]byte
+}
+
+// NewIdent derives the configuration ident from headers, which changes as the setup changes.
+func NewIdent(headers [][]byte) uint32 {
+	var c uint32
+	for _, h := range headers {
+		c = crc.Update(c, h)
+	}
+	return c & 0xffffff
+}
+
+// packHeaders packs headers in the form of packed configuration:
+// the number of headers minus 1 and the lengths of headers but the last in base 128,
+// followed by the headers.
+func packHeaders(headers [][]byte) ([]byte, error) {
+	if len(headers) != 3 {
+		return nil, fmt.Errorf("3 headers are required, got %d", len(headers))
+	}
+	b := appendBase128(nil, uint64(len(headers)-1))
+	for _, h := range headers[:len(headers)-1] {
+		b = appendBase128(b, uint64(len(h)))
+	}
+	for _, h := range headers {
+		b = append(b, h...)
+	}
+	return b, nil
+}
+
+// unpackHeaders unpacks headers packed by packHeaders. The last header takes the rest of b.
+func unpackHeaders(b []byte) ([][]byte, error) {
+	n, b, err := readBase128(b)
+	if err != nil {
+		return nil, err
+	}
+	if n != 2 {
+		return nil, fmt.Errorf("3 headers are required, got %d", n+1)
+	}
+	headers := make([][]byte, n+1)
+	sizes := make([]uint64, n)
+	for i := range sizes {
+		sizes[i], b, err = readBase128(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, size := range sizes {
+		if size > uint64(len(b)) {
+			return nil, errors.New("packed headers are truncated")
+		}
+		headers[i] = b[:size:size]
+		b = b[size:]
+	}
+	headers[n] = b
+	return headers, nil
+}
+
+func appendBase128(b []byte, v uint64) []byte {
+	n := 1
+	for v>>(7*n) != 0 {
+		n++
+	}
+	for i := n - 1; i > 0; i-- {
+		b = append(b, byte(v>>(7*i))|0x80)
+	}
+	return append(b, byte(v&0x7f))
+}
+
+// readBase128 reads a big endian integer of 7 bits per byte, whose most significant bit is set except the last byte.
+func readBase128(b []byte) (uint64, []byte, error) {
+	var v uint64
+	for i, c := range b {
+		if i >= 8 {
+			break
+		}
+		v = v<<7 | uint64(c&0x7f)
+		if c&0x80 == 0 {
+			return v, b[i+1:], nil
+		}
+	}
+	return 0, nil, errors.New("invalid base 128 integer")
+}
+
+// MarshalConfig encodes configurations in the form of packed headers delivered out of band,
+// to be encoded in base64 for the configuration parameter of SDP:
+// 32 bits of the number of configurations, and for each, 24 bits of ident, 16 bits of the sum of lengths of headers,
+// and packed headers.
+func MarshalConfig(configs []Config) ([]byte, error) {
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(configs)))
+	for _, c := range configs {
+		if c.Ident >= 1<<24 {
+			return nil, fmt.Errorf("ident exceeds 24 bits: %x", c.Ident)
+		}
+		packed, err := packHeaders(c.Headers)
+		if err != nil {
+			return nil, err
+		}
+		total := 0
+		for _, h := range c.Headers {
+			total += len(h)
+		}
+		if total >= 1<<16 {
+			return nil, fmt.Errorf("headers of %d bytes are too large", total)
+		}
+		b = append(b, byte(c.Ident>>16), byte(c.Ident>>8), byte(c.Ident))
+		b = binary.BigEndian.AppendUint16(b, uint16(total))
+		b = append(b, packed...)
+	}
+	return b, nil
+}
+
+// ParseConfig decodes configurations encoded by MarshalConfig. Headers share memory with b.
+func ParseConfig(b []byte) ([]Config, error) {
+	if len(b) < 4 {
+		return nil, errors.New("packed headers are truncated")
+	}
+	n := binary.BigEndian.Uint32(b)
+	b = b[4:]
+	var configs []Config
+	for i := uint32(0); i < n; i++ {
+		if len(b) < 5 {
+			return nil, errors.New("packed headers are truncated")
+		}
+		ident := uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+		total := int(binary.BigEndian.Uint16(b[3:5]))
+		b = b[5:]
+
+		// the length of packed headers is known after reading the lengths of headers
+		n, rest, err := readBase128(b)
+		if err != nil {
+			return nil, err
+		}
+		for j := uint64(0); j < n && err == nil; j++ {
+			_, rest, err = readBase128(rest)
+		}
+		if err != nil {
+			return nil, err
+		}
+		size := len(b) - len(rest) + total
+		if size > len(b) {
+			return nil, errors.New("packed headers are truncated")
+		}
+		headers, err := unpackHeaders(b[:size:size])
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, Config{Ident: ident, Headers: headers})
+		b = b[size:]
+	}
+	return configs, nil
+}
@@ -0,0 +1,119 @@
+package rtp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sr8e/vorbis/vorbis"
+)
+
+// Unit is a Vorbis packet, packed configuration or comment header reassembled from RTP packets.
+type Unit struct {
+	DataType  int
+	Ident     uint32
+	Timestamp uint32
+	// Data shares memory with the payload unless reassembled from fragments.
+	Data []byte
+}
+
+// Depacketizer reassembles Vorbis packets from RTP packets in order of sequence numbers.
+// Packets arriving out of order are treated as lost, and dropped if arriving after the following ones.
+type Depacketizer struct {
+	// Configs are the known configurations by ident, given out of band or received in band.
+	Configs map[uint32]Config
+	// Lost is the number of RTP packets lost, or dropped as a part of incomplete fragments.
+	Lost int
+	// Late is the number of RTP packets dropped since they arrive late or duplicated.
+	Late int
+
+	started bool
+	nextSeq uint16
+	// fragments being reassembled
+	frag      []byte
+	fragging  bool
+	fragUnit  Unit
+	fragCount int
+}
+
+// Push consumes an RTP packet, and returns units completed by it.
+// Configurations in band are added to Configs as well as returned.
+func (d *Depacketizer) Push(p Packet) ([]Unit, error) {
+	if d.started && p.Seq != d.nextSeq {
+		// sequence numbers wrap around, and the half behind the expected one is regarded as the past.
+		diff := p.Seq - d.nextSeq
+		if diff >= 0x8000 {
+			d.Late++
+			return nil, nil
+		}
+		d.Lost += int(diff)
+		d.dropFragments()
+	}
+	d.started = true
+	d.nextSeq = p.Seq + 1
+
+	pl, err := ParsePayload(p.Payload)
+	if err != nil {
+		d.dropFragments()
+		return nil, err
+	}
+
+	var units []Unit
+	switch pl.Fragment {
+	case notFragmented:
+		d.dropFragments()
+		for _, data := range pl.Packets {
+			units = append(units, Unit{DataType: pl.DataType, Ident: pl.Ident, Timestamp: p.Timestamp, Data: data})
+		}
+	case fragmentStart:
+		d.dropFragments()
+		d.fragging = true
+		d.fragCount = 1
+		d.fragUnit = Unit{DataType: pl.DataType, Ident: pl.Ident, Timestamp: p.Timestamp}
+		d.frag = append(d.frag[:0], pl.Packets[0]...)
+	case fragmentCont, fragmentEnd:
+		if !d.fragging || pl.Ident != d.fragUnit.Ident || pl.DataType != d.fragUnit.DataType {
+			// the first fragment is lost
+			d.dropFragments()
+			d.Lost++
+			return nil, nil
+		}
+		d.frag = append(d.frag, pl.Packets[0]...)
+		d.fragCount++
+		if pl.Fragment == fragmentEnd {
+			u := d.fragUnit
+			u.Data = append([]byte(nil), d.frag...)
+			units = append(units, u)
+			d.fragging = false
+		}
+	}
+
+	for _, u := range units {
+		if u.DataType != DataConfig {
+			continue
+		}
+		headers, err := unpackHeaders(u.Data)
+		if err != nil {
+			return units, fmt.Errorf("configuration %06x: %w", u.Ident, err)
+		}
+		if d.Configs == nil {
+			d.Configs = map[uint32]Config{}
+		}
+		d.Configs[u.Ident] = Config{Ident: u.Ident, Headers: headers}
+	}
+	return units, nil
+}
+
+func (d *Depacketizer) dropFragments() {
+	if d.fragging {
+		d.Lost += d.fragCount
+		d.fragging = false
+	}
+}
+
+// NewDecoder returns the decoder of Vorbis packets encoded with configuration c, whose headers are read with opts.
+func NewDecoder(c Config, opts vorbis.HeaderOptions) (*vorbis.Decoder, error) {
+	if len(c.Headers) != 3 {
+		return nil, errors.New("configuration must have 3 headers")
+	}
+	return vorbis.NewDecoder(c.Headers[0], c.Headers[1], c.Headers[2], opts)
+}
@@ -0,0 +1,187 @@
+package rtp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sr8e/vorbis/ogg"
+	"github.com/sr8e/vorbis/vorbis"
+)
+
+// readTestPackets returns headers and audio packets of the test stream.
+func readTestPackets(t *testing.T) (headers, audio [][]byte) {
+	t.Helper()
+	var ol ogg.OggLoader
+	if err := ol.Open("../testdata/test.ogg"); err != nil {
+		t.Fatal(err)
+	}
+	defer ol.Close()
+	for {
+		_, p, err := ol.NextPacket(context.Background())
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(headers) < 3 {
+			headers = append(headers, p.Bytes())
+		} else {
+			audio = append(audio, p.Bytes())
+		}
+	}
+	return headers, audio
+}
+
+// packetize returns RTP packets of the configuration in band, the comment and audio packets.
+func packetize(t *testing.T, pz *Packetizer, headers, audio [][]byte) []Packet {
+	t.Helper()
+	c := Config{Ident: NewIdent(headers), Headers: headers}
+	packets, err := pz.AddConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets = append(packets, pz.AddComment(headers[1], 0)...)
+	for i, data := range audio {
+		packets = append(packets, pz.Add(data, uint32(i))...)
+	}
+	return append(packets, pz.Flush()...)
+}
+
+// checkUnits checks units reassembled from packets of packetize.
+func checkUnits(t *testing.T, d *Depacketizer, units []Unit, headers, audio [][]byte) {
+	t.Helper()
+	var got [][]byte
+	for _, u := range units {
+		if u.DataType == DataRaw {
+			got = append(got, u.Data)
+		}
+	}
+	if len(got) != len(audio) {
+		t.Fatalf("got %d packets, want %d", len(got), len(audio))
+	}
+	for i := range got {
+		if !bytes.Equal(got[i], audio[i]) {
+			t.Errorf("packet %d differs", i)
+		}
+	}
+	c, ok := d.Configs[NewIdent(headers)]
+	if !ok {
+		t.Fatal("configuration is not received")
+	}
+	for i := range headers {
+		if !bytes.Equal(c.Headers[i], headers[i]) {
+			t.Errorf("header %d differs", i)
+		}
+	}
+	if _, err := NewDecoder(c, vorbis.HeaderOptions{}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	headers, audio := readTestPackets(t)
+	for _, size := range []int{0, 100, 7} {
+		// sequence numbers wrap around during the stream
+		pz := Packetizer{Seq: 0xfff0, MaxPayloadSize: size}
+		var d Depacketizer
+		var units []Unit
+		for _, p := range packetize(t, &pz, headers, audio) {
+			p, err := ParsePacket(p.Marshal())
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := d.Push(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			units = append(units, u...)
+		}
+		checkUnits(t, &d, units, headers, audio)
+		if d.Lost != 0 || d.Late != 0 {
+			t.Errorf("payload size %d: %d lost and %d late, want none", size, d.Lost, d.Late)
+		}
+	}
+}
+
+func TestRoundTripUDP(t *testing.T) {
+	headers, audio := readTestPackets(t)
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+	sender, err := net.DialUDP("udp", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	var pz Packetizer
+	packets := packetize(t, &pz, headers, audio)
+	var d Depacketizer
+	var units []Unit
+	buf := make([]byte, 1500)
+	// packets are sent one by one, so that loopback never drops them
+	for _, p := range packets {
+		if _, err := sender.Write(p.Marshal()); err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := ParsePacket(bytes.Clone(buf[:n]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := d.Push(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		units = append(units, u...)
+	}
+	checkUnits(t, &d, units, headers, audio)
+}
+
+func TestPushLate(t *testing.T) {
+	pz := Packetizer{Seq: 0xfffe}
+	var packets []Packet
+	for i := 0; i < 4; i++ {
+		packets = append(packets, pz.Add([]byte{byte(i)}, uint32(i))...)
+		packets = append(packets, pz.Flush()...)
+	}
+	var d Depacketizer
+	var got []byte
+	// the third packet is reordered after the fourth, and the first one is duplicated
+	for _, i := range []int{0, 1, 3, 2, 0} {
+		units, err := d.Push(packets[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range units {
+			got = append(got, u.Data...)
+		}
+	}
+	if want := []byte{0, 1, 3}; !bytes.Equal(got, want) {
+		t.Errorf("got packets %v, want %v", got, want)
+	}
+	if d.Lost != 1 || d.Late != 2 {
+		t.Errorf("got %d lost and %d late, want 1 and 2", d.Lost, d.Late)
+	}
+	// the state is kept, so that the next packet follows
+	packets = append(packets, pz.Add([]byte{4}, 4)...)
+	packets = append(packets, pz.Flush()...)
+	if _, err := d.Push(packets[4]); err != nil {
+		t.Fatal(err)
+	}
+	if d.Lost != 1 {
+		t.Errorf("got %d lost after the late packets, want 1", d.Lost)
+	}
+}
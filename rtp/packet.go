@@ -0,0 +1,66 @@
+// Package rtp implements RTP payload format of Vorbis (RFC 5215).
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Packet is an RTP packet. CSRC list and header extension are not kept.
+type Packet struct {
+	Marker      bool
+	PayloadType uint8
+	Seq         uint16
+	Timestamp   uint32
+	SSRC        uint32
+	Payload     []byte
+}
+
+// Marshal encodes the packet with the fixed header of 12 bytes.
+func (p *Packet) Marshal() []byte {
+	b := make([]byte, 12+len(p.Payload))
+	b[0] = 2 << 6 // version
+	b[1] = p.PayloadType & 0x7f
+	if p.Marker {
+		b[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(b[2:4], p.Seq)
+	binary.BigEndian.PutUint32(b[4:8], p.Timestamp)
+	binary.BigEndian.PutUint32(b[8:12], p.SSRC)
+	copy(b[12:], p.Payload)
+	return b
+}
+
+// ParsePacket decodes an RTP packet. Payload shares memory with b.
+func ParsePacket(b []byte) (Packet, error) {
+	if len(b) < 12 {
+		return Packet{}, errors.New("RTP packet is too short")
+	}
+	if b[0]>>6 != 2 {
+		return Packet{}, fmt.Errorf("unsupported RTP version %d", b[0]>>6)
+	}
+	p := Packet{
+		Marker:      b[1]&0x80 != 0,
+		PayloadType: b[1] & 0x7f,
+		Seq:         binary.BigEndian.Uint16(b[2:4]),
+		Timestamp:   binary.BigEndian.Uint32(b[4:8]),
+		SSRC:        binary.BigEndian.Uint32(b[8:12]),
+	}
+	pos := 12 + 4*int(b[0]&0x0f) // CSRC list
+	if b[0]&0x10 != 0 {          // header extension
+		if len(b) < pos+4 {
+			return Packet{}, errors.New("RTP header extension is truncated")
+		}
+		pos += 4 + 4*int(binary.BigEndian.Uint16(b[pos+2:pos+4]))
+	}
+	end := len(b)
+	if b[0]&0x20 != 0 { // padding, whose length is the last byte
+		end -= int(b[end-1])
+	}
+	if pos > end {
+		return Packet{}, errors.New("RTP packet is truncated")
+	}
+	p.Payload = b[pos:end]
+	return p, nil
+}
@@ -0,0 +1,110 @@
+package rtp
+
+// DefaultMaxPayloadSize is the default size limit of payloads, which fits in typical MTU with IP, UDP and RTP headers.
+const DefaultMaxPayloadSize = 1400
+
+// Packetizer aggregates and fragments Vorbis packets into RTP packets.
+type Packetizer struct {
+	PayloadType uint8
+	SSRC        uint32
+	// Seq is the sequence number of the next RTP packet.
+	Seq uint16
+	// Ident is the ident of the configuration packets are encoded with.
+	Ident uint32
+	// MaxPayloadSize limits the size of RTP payloads. DefaultMaxPayloadSize is used if zero.
+	MaxPayloadSize int
+
+	// packets to be aggregated
+	pending   [][]byte
+	size      int // size of payload of pending packets
+	timestamp uint32
+}
+
+// Add adds an audio packet, whose first sample is at timestamp in the clock of sample rate.
+// It returns RTP packets completed, while the packet may be held to be aggregated with following ones.
+func (pz *Packetizer) Add(data []byte, timestamp uint32) []Packet {
+	var packets []Packet
+	if len(pz.pending) > 0 && (len(pz.pending) == maxPackets || pz.size+2+len(data) > pz.maxSize()) {
+		packets = pz.Flush()
+	}
+	if 4+2+len(data) > pz.maxSize() {
+		return append(packets, pz.fragment(DataRaw, data, timestamp)...)
+	}
+	if len(pz.pending) == 0 {
+		pz.timestamp = timestamp
+		pz.size = 4
+	}
+	pz.pending = append(pz.pending, data)
+	pz.size += 2 + len(data)
+	return packets
+}
+
+// Flush returns the RTP packet of packets held.
+func (pz *Packetizer) Flush() []Packet {
+	if len(pz.pending) == 0 {
+		return nil
+	}
+	p := pz.packet(Payload{Ident: pz.Ident, DataType: DataRaw, Packets: pz.pending}, pz.timestamp)
+	pz.pending = nil
+	return []Packet{p}
+}
+
+// AddConfig flushes packets held, and returns RTP packets of the configuration delivered in band.
+// Ident is set to that of the configuration.
+func (pz *Packetizer) AddConfig(c Config) ([]Packet, error) {
+	packed, err := packHeaders(c.Headers)
+	if err != nil {
+		return nil, err
+	}
+	packets := pz.Flush()
+	pz.Ident = c.Ident
+	return append(packets, pz.fragment(DataConfig, packed, 0)...), nil
+}
+
+// AddComment flushes packets held, and returns RTP packets of the comment header.
+func (pz *Packetizer) AddComment(comment []byte, timestamp uint32) []Packet {
+	packets := pz.Flush()
+	return append(packets, pz.fragment(DataComment, comment, timestamp)...)
+}
+
+// fragment returns RTP packets of data alone, which is fragmented if it does not fit in a payload.
+func (pz *Packetizer) fragment(dataType int, data []byte, timestamp uint32) []Packet {
+	chunk := pz.maxSize() - 4 - 2
+	if len(data) <= chunk {
+		return []Packet{pz.packet(Payload{Ident: pz.Ident, DataType: dataType, Packets: [][]byte{data}}, timestamp)}
+	}
+	var packets []Packet
+	for pos := 0; pos < len(data); pos += chunk {
+		end := min(pos+chunk, len(data))
+		fragment := fragmentCont
+		if pos == 0 {
+			fragment = fragmentStart
+		} else if end == len(data) {
+			fragment = fragmentEnd
+		}
+		pl := Payload{Ident: pz.Ident, Fragment: fragment, DataType: dataType, Packets: [][]byte{data[pos:end]}}
+		packets = append(packets, pz.packet(pl, timestamp))
+	}
+	return packets
+}
+
+func (pz *Packetizer) packet(pl Payload, timestamp uint32) Packet {
+	// sizes are bounded by maxSize, so that it never fails
+	b, _ := pl.Marshal()
+	p := Packet{
+		PayloadType: pz.PayloadType,
+		Seq:         pz.Seq,
+		Timestamp:   timestamp,
+		SSRC:        pz.SSRC,
+		Payload:     b,
+	}
+	pz.Seq++
+	return p
+}
+
+func (pz *Packetizer) maxSize() int {
+	if pz.MaxPayloadSize == 0 {
+		return DefaultMaxPayloadSize
+	}
+	return min(max(pz.MaxPayloadSize, 4+2+1), 4+2+1<<16-1)
+}
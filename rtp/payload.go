@@ -0,0 +1,104 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Vorbis data type of payload
+const (
+	DataRaw     = 0 // audio packets
+	DataConfig  = 1 // packed configuration in band
+	DataComment = 2 // comment header
+)
+
+// fragment type of payload
+const (
+	notFragmented = 0
+	fragmentStart = 1
+	fragmentCont  = 2
+	fragmentEnd   = 3
+)
+
+// maxPackets is the maximum number of packets aggregated into a payload.
+const maxPackets = 15
+
+// Payload is the content of RTP packet of Vorbis:
+// 24 bits of configuration ident, 2 bits of fragment type, 2 bits of data type and 4 bits of number of packets,
+// followed by packets or a fragment, each prefixed by 16 bits of length.
+type Payload struct {
+	Ident    uint32
+	Fragment int // 0 for complete packets, 1 for the first fragment, 2 for continuation and 3 for the last
+	DataType int
+	Packets  [][]byte // a fragment if fragmented
+}
+
+// Marshal encodes the payload.
+func (pl *Payload) Marshal() ([]byte, error) {
+	if pl.Ident >= 1<<24 {
+		return nil, fmt.Errorf("ident exceeds 24 bits: %x", pl.Ident)
+	}
+	num := len(pl.Packets)
+	if pl.Fragment != notFragmented {
+		if num != 1 {
+			return nil, errors.New("fragmented payload must have exactly one fragment")
+		}
+		num = 0
+	} else if num == 0 || num > maxPackets {
+		return nil, fmt.Errorf("payload cannot have %d packets", num)
+	}
+	size := 4
+	for _, p := range pl.Packets {
+		if len(p) >= 1<<16 {
+			return nil, fmt.Errorf("packet of %d bytes must be fragmented", len(p))
+		}
+		size += 2 + len(p)
+	}
+	b := make([]byte, 4, size)
+	binary.BigEndian.PutUint32(b, pl.Ident<<8|uint32(pl.Fragment&3)<<6|uint32(pl.DataType&3)<<4|uint32(num))
+	for _, p := range pl.Packets {
+		b = binary.BigEndian.AppendUint16(b, uint16(len(p)))
+		b = append(b, p...)
+	}
+	return b, nil
+}
+
+// ParsePayload decodes the payload of RTP packet. Packets share memory with b.
+func ParsePayload(b []byte) (Payload, error) {
+	if len(b) < 4 {
+		return Payload{}, errors.New("payload header is truncated")
+	}
+	v := binary.BigEndian.Uint32(b)
+	pl := Payload{
+		Ident:    v >> 8,
+		Fragment: int(v>>6) & 3,
+		DataType: int(v>>4) & 3,
+	}
+	num := int(v & 0xf)
+	if pl.DataType == 3 {
+		return Payload{}, errors.New("reserved data type")
+	}
+	if pl.Fragment != notFragmented {
+		if num != 0 {
+			return Payload{}, errors.New("fragmented payload has number of packets")
+		}
+		num = 1
+	} else if num == 0 {
+		return Payload{}, errors.New("payload has no packet")
+	}
+	b = b[4:]
+	pl.Packets = make([][]byte, num)
+	for i := range pl.Packets {
+		if len(b) < 2 {
+			return Payload{}, errors.New("packet length is truncated")
+		}
+		l := int(binary.BigEndian.Uint16(b))
+		if len(b) < 2+l {
+			return Payload{}, errors.New("packet is truncated")
+		}
+		pl.Packets[i] = b[2 : 2+l : 2+l]
+		b = b[2+l:]
+	}
+	return pl, nil
+}
@@ -2,41 +2,45 @@ package transform
 
 import (
 	"math"
-	"math/cmplx"
 )
 
-func DCT4(data []float64, bits int) []float64 {
+func DCT4[F Float](data []F, bits int) []F {
 	N := 1 << bits
 	if len(data) != N {
 		return nil
 	}
 
 	// pack the data into length of N/2 complex array
-	cmpData := make([]complex128, N/2)
-	for i := range cmpData {
+	re := make([]F, N/2)
+	im := make([]F, N/2)
+	for i := range re {
 		// pre-rotation
-		cmpData[i] = complex(data[2*i], data[N-1-2*i]) * cmplx.Rect(1, -math.Pi*float64(i)/float64(N))
+		s, c := math.Sincos(-math.Pi * float64(i) / float64(N))
+		a, b := data[2*i], data[N-1-2*i]
+		re[i] = a*F(c) - b*F(s)
+		im[i] = a*F(s) + b*F(c)
 	}
 
-	cmpCoef := FFT(cmpData, bits-1)
+	coefRe, coefIm := fftKernel(re, im, bits-1, false)
 
 	// unpack the coefficient
-	res := make([]float64, N)
-	for i, v := range cmpCoef {
+	res := make([]F, N)
+	for i := range coefRe {
 		// post-rotation
-		post := v * cmplx.Rect(1, -math.Pi*float64(4*i+1)/float64(4*N))
-		res[2*i] = real(post)
-		res[N-1-2*i] = -imag(post)
+		s, c := math.Sincos(-math.Pi * float64(4*i+1) / float64(4*N))
+		a, b := coefRe[i], coefIm[i]
+		res[2*i] = a*F(c) - b*F(s)
+		res[N-1-2*i] = -(a*F(s) + b*F(c))
 	}
 
 	return res
 }
 
-func IDCT4(data []float64, bits int) []float64 {
+func IDCT4[F Float](data []F, bits int) []F {
 	f := DCT4(data, bits)
 	N := 1 << bits
 	for i := range f {
-		f[i] /= float64(N) / 2
+		f[i] /= F(N) / 2
 	}
 	return f
 }
@@ -2,12 +2,12 @@ package transform
 
 import (
 	"math"
-	"math/cmplx"
 )
 
-func rotationFactor(bits int, inverse bool) []complex128 {
+func rotationFactor[F Float](bits int, inverse bool) ([]F, []F) {
 	N := 1 << bits
-	W := make([]complex128, N)
+	wRe := make([]F, N)
+	wIm := make([]F, N)
 
 	c := -2.0
 	if inverse {
@@ -15,9 +15,11 @@ func rotationFactor(bits int, inverse bool) []complex128 {
 	}
 
 	for i := 0; i < N; i++ {
-		W[i] = cmplx.Rect(1, c*float64(i)*math.Pi/float64(N))
+		s, co := math.Sincos(c * float64(i) * math.Pi / float64(N))
+		wRe[i] = F(co)
+		wIm[i] = F(s)
 	}
-	return W
+	return wRe, wIm
 }
 
 func bitReverse(bits int) []int {
@@ -31,45 +33,85 @@ func bitReverse(bits int) []int {
 	return seq
 }
 
-func fftKernel(data []complex128, bits int, inverse bool) []complex128 {
+// fftKernel works on the real and imaginary parts held in separate slices,
+// so that the same kernel serves every Float precision.
+func fftKernel[F Float](re, im []F, bits int, inverse bool) ([]F, []F) {
 	N := 1 << bits
 
-	if len(data) != N {
-		return nil
+	if len(re) != N || len(im) != N {
+		return nil, nil
 	}
 
-	rev := bitReverse(bits)
-	W := rotationFactor(bits, inverse)
+	resRe := make([]F, N)
+	resIm := make([]F, N)
+	copy(resRe, re)
+	copy(resIm, im)
+	wRe, wIm := rotationFactor[F](bits, inverse)
+	fftInto(resRe, resIm, make([]F, N), make([]F, N), wRe, wIm, bitReverse(bits), bits)
+	return resRe, resIm
+}
+
+// fftInto performs FFT of (re, im) in place, using (bufRe, bufIm) as working memory.
+func fftInto[F Float](re, im, bufRe, bufIm, wRe, wIm []F, rev []int, bits int) {
+	N := 1 << bits
 
-	prev := make([]complex128, N)
 	for i := 0; i < N; i++ {
-		prev[i] = data[rev[i]]
+		bufRe[i] = re[rev[i]]
+		bufIm[i] = im[rev[i]]
 	}
 
+	prevRe, prevIm := bufRe, bufIm
+	nextRe, nextIm := re, im
 	for i := 0; i < bits; i++ {
-		next := make([]complex128, N)
+		clear(nextRe)
+		clear(nextIm)
 		for j := 0; j < N; j++ {
 			ofs := 1 << i
 			if (j>>i)%2 == 0 {
-				next[j] += prev[j]
-				next[j+ofs] += prev[j]
+				nextRe[j] += prevRe[j]
+				nextIm[j] += prevIm[j]
+				nextRe[j+ofs] += prevRe[j]
+				nextIm[j+ofs] += prevIm[j]
 			} else {
 				rotIndex := (j << (bits - i - 1)) % N
-				next[j-ofs] += prev[j] * W[rotIndex-N/2]
-				next[j] += prev[j] * W[rotIndex]
+				r, m := rotIndex-N/2, rotIndex
+				nextRe[j-ofs] += prevRe[j]*wRe[r] - prevIm[j]*wIm[r]
+				nextIm[j-ofs] += prevRe[j]*wIm[r] + prevIm[j]*wRe[r]
+				nextRe[j] += prevRe[j]*wRe[m] - prevIm[j]*wIm[m]
+				nextIm[j] += prevRe[j]*wIm[m] + prevIm[j]*wRe[m]
 			}
 		}
-		prev = next
+		prevRe, prevIm, nextRe, nextIm = nextRe, nextIm, prevRe, prevIm
+	}
+	if bits%2 == 0 {
+		copy(re, prevRe)
+		copy(im, prevIm)
+	}
+}
+
+func complexKernel(data []complex128, bits int, inverse bool) []complex128 {
+	re := make([]float64, len(data))
+	im := make([]float64, len(data))
+	for i, v := range data {
+		re[i], im[i] = real(v), imag(v)
+	}
+	re, im = fftKernel(re, im, bits, inverse)
+	if re == nil {
+		return nil
+	}
+	res := make([]complex128, len(re))
+	for i := range res {
+		res[i] = complex(re[i], im[i])
 	}
-	return prev
+	return res
 }
 
 func FFT(data []complex128, bits int) []complex128 {
-	return fftKernel(data, bits, false)
+	return complexKernel(data, bits, false)
 }
 
 func IFFT(data []complex128, bits int) []complex128 {
-	f := fftKernel(data, bits, true)
+	f := complexKernel(data, bits, true)
 	N := 1 << bits
 	for i := range f {
 		f[i] /= complex(float64(N), 0)
@@ -0,0 +1,266 @@
+package transform
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Fixed point transforms use only integer arithmetic, so that the results are
+// bit-identical on every architecture. Twiddle factors and windows are Q30 values
+// generated by CORDIC instead of math package.
+
+const fixedOne = 1 << 30
+
+// cordicAtan[i] is atan(2^-i) in units of 2^-32 turn.
+var cordicAtan = [30]int64{
+	536870912, 316933406, 167458907, 85004756, 42667331, 21354465, 10679838, 5340245,
+	2670163, 1335087, 667544, 333772, 166886, 83443, 41722, 20861,
+	10430, 5215, 2608, 1304, 652, 326, 163, 81,
+	41, 20, 10, 5, 3, 1,
+}
+
+// cordicGain is the reciprocal of CORDIC gain in Q30.
+const cordicGain = 652032874
+
+// sinCosFixed returns sine and cosine in Q30 of the angle given in units of 2^-32 turn.
+func sinCosFixed(angle uint32) (int64, int64) {
+	// reduce the angle into [-1/8, 1/8) turn around the nearest quadrant
+	quadrant := (angle + 1<<29) >> 30
+	z := int64(int32(angle - quadrant<<30))
+
+	var x, y int64 = cordicGain, 0
+	for i, a := range cordicAtan {
+		if z >= 0 {
+			x, y, z = x-y>>i, y+x>>i, z-a
+		} else {
+			x, y, z = x+y>>i, y-x>>i, z+a
+		}
+	}
+
+	switch quadrant & 3 {
+	case 1:
+		return x, -y
+	case 2:
+		return -y, -x
+	case 3:
+		return -x, y
+	}
+	return y, x
+}
+
+// mulQ30 multiplies a by Q30 value b, rounding toward zero without intermediate overflow.
+func mulQ30(a, b int64) int64 {
+	neg := (a < 0) != (b < 0)
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+	hi, lo := bits.Mul64(uint64(a), uint64(b))
+	v := int64(hi<<34 | lo>>30)
+	if neg {
+		return -v
+	}
+	return v
+}
+
+// Saturate32 clamps v into the range of int32.
+func Saturate32(v int64) int32 {
+	if v > math.MaxInt32 {
+		return math.MaxInt32
+	} else if v < math.MinInt32 {
+		return math.MinInt32
+	}
+	return int32(v)
+}
+
+// fftIntoFixed performs FFT of (re, im) in place, using (bufRe, bufIm) as working memory.
+func fftIntoFixed(re, im, bufRe, bufIm, wRe, wIm []int64, rev []int, bits int) {
+	N := 1 << bits
+
+	for i := 0; i < N; i++ {
+		bufRe[i] = re[rev[i]]
+		bufIm[i] = im[rev[i]]
+	}
+
+	prevRe, prevIm := bufRe, bufIm
+	nextRe, nextIm := re, im
+	for i := 0; i < bits; i++ {
+		clear(nextRe)
+		clear(nextIm)
+		for j := 0; j < N; j++ {
+			ofs := 1 << i
+			if (j>>i)%2 == 0 {
+				nextRe[j] += prevRe[j]
+				nextIm[j] += prevIm[j]
+				nextRe[j+ofs] += prevRe[j]
+				nextIm[j+ofs] += prevIm[j]
+			} else {
+				rotIndex := (j << (bits - i - 1)) % N
+				r, m := rotIndex-N/2, rotIndex
+				nextRe[j-ofs] += mulQ30(prevRe[j], wRe[r]) - mulQ30(prevIm[j], wIm[r])
+				nextIm[j-ofs] += mulQ30(prevRe[j], wIm[r]) + mulQ30(prevIm[j], wRe[r])
+				nextRe[j] += mulQ30(prevRe[j], wRe[m]) - mulQ30(prevIm[j], wIm[m])
+				nextIm[j] += mulQ30(prevRe[j], wIm[m]) + mulQ30(prevIm[j], wRe[m])
+			}
+		}
+		prevRe, prevIm, nextRe, nextIm = nextRe, nextIm, prevRe, prevIm
+	}
+	if bits%2 == 0 {
+		copy(re, prevRe)
+		copy(im, prevIm)
+	}
+}
+
+// IMDCTFixed is the integer counterpart of IMDCT, windowed by Q30 window function.
+// Input and output share the same fixed point scale.
+// Unlike IMDCT, the result is not normalized: it is N/4 times as large as IMDCT.
+func IMDCTFixed(data []int32, sampleBits int, windowFunc func(int, int) int32) []int32 {
+	N := 1 << sampleBits
+	if len(data) != N/2 {
+		return nil
+	}
+
+	if windowFunc == nil {
+		windowFunc = RectWindowFixed
+	}
+
+	res := make([]int32, N)
+	NewIMDCTFixedPlan(sampleBits).Transform(res, data, WindowTableFixed(windowFunc, sampleBits))
+	return res
+}
+
+// IMDCTFixedPlan is the fixed point counterpart of IMDCTPlan.
+type IMDCTFixedPlan struct {
+	sampleBits int
+	rev        []int
+	wRe, wIm   []int64
+	preRe      []int64
+	preIm      []int64
+	postRe     []int64
+	postIm     []int64
+	re, im     []int64
+	bufRe      []int64
+	bufIm      []int64
+	out        []int64
+}
+
+func NewIMDCTFixedPlan(sampleBits int) *IMDCTFixedPlan {
+	N := 1 << sampleBits
+	fftBits := sampleBits - 2
+	dctBits := sampleBits - 1
+
+	pl := &IMDCTFixedPlan{
+		sampleBits: sampleBits,
+		rev:        bitReverse(fftBits),
+		wRe:        make([]int64, N/4),
+		wIm:        make([]int64, N/4),
+		preRe:      make([]int64, N/4),
+		preIm:      make([]int64, N/4),
+		postRe:     make([]int64, N/4),
+		postIm:     make([]int64, N/4),
+		re:         make([]int64, N/4),
+		im:         make([]int64, N/4),
+		bufRe:      make([]int64, N/4),
+		bufIm:      make([]int64, N/4),
+		out:        make([]int64, N),
+	}
+	for i := range pl.wRe {
+		// -2πi/(N/4)
+		pl.wIm[i], pl.wRe[i] = sinCosFixed(-uint32(i << (32 - fftBits)))
+		// pre-rotation by -πi/(N/2)
+		pl.preIm[i], pl.preRe[i] = sinCosFixed(-uint32(i << (31 - dctBits)))
+		// post-rotation by -π(4i+1)/2N
+		pl.postIm[i], pl.postRe[i] = sinCosFixed(-uint32((4*i + 1) << (29 - dctBits)))
+	}
+	return pl
+}
+
+// Transform writes IMDCTFixed of data into dst.
+// window holds Q30 value of window function for each sample, and rectangular window is applied if nil.
+func (pl *IMDCTFixedPlan) Transform(dst, data, window []int32) {
+	N := 1 << pl.sampleBits
+	M := N / 2
+
+	for i := range pl.re {
+		a, b := int64(data[2*i]), int64(data[M-1-2*i])
+		pl.re[i] = mulQ30(a, pl.preRe[i]) - mulQ30(b, pl.preIm[i])
+		pl.im[i] = mulQ30(a, pl.preIm[i]) + mulQ30(b, pl.preRe[i])
+	}
+	fftIntoFixed(pl.re, pl.im, pl.bufRe, pl.bufIm, pl.wRe, pl.wIm, pl.rev, pl.sampleBits-2)
+
+	// unpack the coefficients (A, B) and wrap them around boundary condition
+	// (A, B) -> (B, -B_rev, -A_rev, -A)
+	for i := range pl.re {
+		a, b := pl.re[i], pl.im[i]
+		even := mulQ30(a, pl.postRe[i]) - mulQ30(b, pl.postIm[i])
+		odd := -(mulQ30(a, pl.postIm[i]) + mulQ30(b, pl.postRe[i]))
+		for _, v := range [2]struct {
+			k   int
+			val int64
+		}{{2 * i, even}, {M - 1 - 2*i, odd}} {
+			if v.k < M/2 { // A
+				pl.out[N*3/4-1-v.k] = -v.val
+				pl.out[N*3/4+v.k] = -v.val
+			} else { // B
+				pl.out[v.k-M/2] = v.val
+				pl.out[N*3/4-1-v.k] = -v.val
+			}
+		}
+	}
+
+	for i, v := range pl.out {
+		w := int64(RectWindowFixed(i, pl.sampleBits))
+		if window != nil {
+			w = int64(window[i])
+		}
+		dst[i] = Saturate32(mulQ30(v, w))
+	}
+}
+
+// WindowTableFixed evaluates Q30 window function for each sample of a block.
+func WindowTableFixed(windowFunc func(int, int) int32, sampleBits int) []int32 {
+	w := make([]int32, 1<<sampleBits)
+	for i := range w {
+		w[i] = windowFunc(i, sampleBits)
+	}
+	return w
+}
+
+// VorbisWindowFixed returns Vorbis power complementary window in Q30.
+func VorbisWindowFixed(i, sampleBits int) int32 {
+	// sin^2(π(2i+1)/2N)
+	s, _ := sinCosFixed(uint32((2*i + 1) << (30 - sampleBits)))
+	sq := mulQ30(s, s)
+	// sin(π/2 * sq): sq in Q30 is the angle in quarter turns
+	w, _ := sinCosFixed(uint32(sq))
+	return int32(w)
+}
+
+// RectWindowFixed is the Q30 counterpart of RectWindow.
+func RectWindowFixed(_, _ int) int32 {
+	return 759250125 // 2^-1/2 in Q30
+}
+
+// VorbisWindowVarWidthFixed is the Q30 counterpart of VorbisWindowVarWidth.
+func VorbisWindowVarWidthFixed(leftBits, rightBits int) func(int, int) int32 {
+	lq := 1 << (leftBits - 2)
+	rq := 1 << (rightBits - 2)
+	return func(i, windowBits int) int32 {
+		Nq := 1 << (windowBits - 2)
+		leftStart := Nq - lq
+		leftEnd := Nq + lq
+		rightStart := Nq*3 - rq
+		rightEnd := Nq*3 + rq
+
+		if leftStart <= i && i < leftEnd {
+			return VorbisWindowFixed(i-leftStart, leftBits)
+		} else if leftEnd <= i && i < rightStart {
+			return fixedOne
+		} else if rightStart <= i && i < rightEnd {
+			return VorbisWindowFixed(i-rightStart+rq*2, rightBits)
+		}
+		return 0
+	}
+}
@@ -0,0 +1,6 @@
+package transform
+
+// Float is the set of sample types the transforms operate on.
+type Float interface {
+	~float32 | ~float64
+}
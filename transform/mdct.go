@@ -1,15 +1,15 @@
 package transform
 
 import (
-	"slices"
+	"math"
 )
 
-func mdctKernel(data []float64, sampleBits int) []float64 {
+func mdctKernel[F Float](data []F, sampleBits int) []F {
 	N := 1 << sampleBits
 
 	// fold input around boundary condition
 	// mdct(a, b, c, d) -> dct4(-c_rev - d, a - b_rev)
-	dctData := make([]float64, N/2)
+	dctData := make([]F, N/2)
 	for i := range dctData {
 		if i < N/4 {
 			dctData[i] = -data[i+N*3/4] - data[N*3/4-1-i]
@@ -21,7 +21,7 @@ func mdctKernel(data []float64, sampleBits int) []float64 {
 	return DCT4(dctData, sampleBits-1)
 }
 
-func MDCT(data []float64, sampleBits int, windowFunc func(int, int) float64) []float64 {
+func MDCT[F Float](data []F, sampleBits int, windowFunc func(int, int) float64) []F {
 	N := 1 << sampleBits
 	if len(data) != N {
 		return nil
@@ -31,15 +31,15 @@ func MDCT(data []float64, sampleBits int, windowFunc func(int, int) float64) []f
 		windowFunc = RectWindow
 	}
 
-	windowed := make([]float64, N)
+	windowed := make([]F, N)
 	for i, v := range data {
-		windowed[i] = v * windowFunc(i, sampleBits)
+		windowed[i] = v * F(windowFunc(i, sampleBits))
 	}
 
 	return mdctKernel(windowed, sampleBits)
 }
 
-func IMDCT(data []float64, sampleBits int, windowFunc func(int, int) float64) []float64 {
+func IMDCT[F Float](data []F, sampleBits int, windowFunc func(int, int) float64) []F {
 	N := 1 << sampleBits
 	if len(data) != N/2 { // coefficients are half the length of samples
 		return nil
@@ -49,21 +49,97 @@ func IMDCT(data []float64, sampleBits int, windowFunc func(int, int) float64) []
 		windowFunc = RectWindow
 	}
 
-	res := IDCT4(data, sampleBits-1)
+	res := make([]F, N)
+	NewIMDCTPlan[F](sampleBits).Transform(res, data, WindowTable[F](windowFunc, sampleBits))
+	return res
+}
+
+// IMDCTPlan performs IMDCT of a fixed size repeatedly without allocation,
+// holding precomputed rotation factors and working buffers.
+type IMDCTPlan[F Float] struct {
+	sampleBits int
+	rev        []int
+	wRe, wIm   []F
+	preRe      []F
+	preIm      []F
+	postRe     []F
+	postIm     []F
+	re, im     []F
+	bufRe      []F
+	bufIm      []F
+}
+
+func NewIMDCTPlan[F Float](sampleBits int) *IMDCTPlan[F] {
+	N := 1 << sampleBits
+	dctLen := N / 2
+	fftBits := sampleBits - 2
+
+	pl := &IMDCTPlan[F]{
+		sampleBits: sampleBits,
+		rev:        bitReverse(fftBits),
+		preRe:      make([]F, N/4),
+		preIm:      make([]F, N/4),
+		postRe:     make([]F, N/4),
+		postIm:     make([]F, N/4),
+		re:         make([]F, N/4),
+		im:         make([]F, N/4),
+		bufRe:      make([]F, N/4),
+		bufIm:      make([]F, N/4),
+	}
+	pl.wRe, pl.wIm = rotationFactor[F](fftBits, false)
+
+	// inverse DCT4 is normalized by 2/dctLen, which is folded into post-rotation
+	norm := 2 / float64(dctLen)
+	for i := range pl.preRe {
+		s, c := math.Sincos(-math.Pi * float64(i) / float64(dctLen))
+		pl.preRe[i], pl.preIm[i] = F(c), F(s)
+		s, c = math.Sincos(-math.Pi * float64(4*i+1) / float64(4*dctLen))
+		pl.postRe[i], pl.postIm[i] = F(c*norm), F(s*norm)
+	}
+	return pl
+}
+
+// Transform writes IMDCT of data into dst, the same as IMDCT does.
+// window holds the value of window function for each sample, and rectangular window is applied if nil.
+func (pl *IMDCTPlan[F]) Transform(dst, data, window []F) {
+	N := 1 << pl.sampleBits
+	M := N / 2
+
+	// inverse DCT4 of data, packed in complex array of length M/2
+	for i := range pl.re {
+		a, b := data[2*i], data[M-1-2*i]
+		pl.re[i] = a*pl.preRe[i] - b*pl.preIm[i]
+		pl.im[i] = a*pl.preIm[i] + b*pl.preRe[i]
+	}
+	fftInto(pl.re, pl.im, pl.bufRe, pl.bufIm, pl.wRe, pl.wIm, pl.rev, pl.sampleBits-2)
 
-	// wrap result around boundary condition
-	// res=(A, B) -> return (B, -B_rev, -A_rev, -A)
-	cat := make([]float64, N)
-	copy(cat, res[N/4:])
-	for i, v := range res {
-		res[i] = -v
+	// unpack the coefficients (A, B) and wrap them around boundary condition
+	// (A, B) -> (B, -B_rev, -A_rev, -A)
+	for i := range pl.re {
+		a, b := pl.re[i], pl.im[i]
+		even := a*pl.postRe[i] - b*pl.postIm[i]
+		odd := -(a*pl.postIm[i] + b*pl.postRe[i])
+		for _, v := range [2]struct {
+			k   int
+			val F
+		}{{2 * i, even}, {M - 1 - 2*i, odd}} {
+			if v.k < M/2 { // A
+				dst[N*3/4-1-v.k] = -v.val
+				dst[N*3/4+v.k] = -v.val
+			} else { // B
+				dst[v.k-M/2] = v.val
+				dst[N*3/4-1-v.k] = -v.val
+			}
+		}
 	}
-	copy(cat[N/4:], res)
-	copy(cat[3*N/4:], res[:N/4])
-	slices.Reverse(cat[N/4 : 3*N/4])
 
-	for i := range cat {
-		cat[i] *= windowFunc(i, sampleBits)
+	if window == nil {
+		for i := range dst[:N] {
+			dst[i] *= F(RectWindow(i, pl.sampleBits))
+		}
+		return
+	}
+	for i, w := range window[:N] {
+		dst[i] *= w
 	}
-	return cat
 }
@@ -33,3 +33,12 @@ func VorbisWindowVarWidth(leftBits, rightBits int) func(int, int) float64 {
 		return 0
 	}
 }
+
+// WindowTable evaluates window function for each sample of a block.
+func WindowTable[F Float](windowFunc func(int, int) float64, sampleBits int) []F {
+	w := make([]F, 1<<sampleBits)
+	for i := range w {
+		w[i] = F(windowFunc(i, sampleBits))
+	}
+	return w
+}
@@ -7,54 +7,129 @@ import (
 	"github.com/sr8e/vorbis/transform"
 )
 
-func readAudioPacket(p *ogg.Packet, ident Identification, vs VorbisSetup) ([][]float64, error) {
+// sample is the set of types residue vectors are decoded into.
+// int32 denotes fixed point decoding.
+type sample interface {
+	~float32 | ~float64 | ~int32
+}
+
+// block is an audio packet decoded into windowed time domain samples.
+type block[S sample] struct {
+	samples [][]S  // to be overlapped and added with adjacent blocks
+	spectra [][]S  // input of inverse MDCT for each channel, in the same scale regardless of block size
+	shape   [3]int // exponents of block size, and of the slopes of window to the left and right
+}
+
+// packetDecoder decodes audio packets into blocks for each channel.
+// Blocks are valid until the next call of decode or synthesize since the buffers are reused.
+type packetDecoder[S sample] interface {
+	decode(p *ogg.Packet) (block[S], error)
+	// synthesize transforms spectra into windowed samples of a block of shape.
+	synthesize(spectra [][]S, shape [3]int) [][]S
+}
+
+// audioBlock holds the decoded content of an audio packet before inverse MDCT.
+type audioBlock[S sample] struct {
+	blockExp int
+	leftExp  int
+	rightExp int
+	floors   [][]int // nil for unused floor
+	residues [][]S
+}
+
+// blockDecoder decodes floors and residues of audio packets, reusing the buffers sized for long block.
+type blockDecoder[S sample] struct {
+	ident Identification
+	setup VorbisSetup
+
+	floors         [][]int
+	floorViews     [][]int
+	residues       [][]S
+	noResidueFlags []bool
+	noDecodeFlags  []bool
+	submapVectors  [][]S
+	floorBuf       floorBuffer
+	residueBuf     residueBuffer[S]
+}
+
+func newBlockDecoder[S sample](ident Identification, vs VorbisSetup) blockDecoder[S] {
+	chNum := int(ident.Channels)
+	half := 1 << (ident.BlockExp[1] - 1)
+	bd := blockDecoder[S]{
+		ident:          ident,
+		setup:          vs,
+		floors:         make([][]int, chNum),
+		floorViews:     make([][]int, chNum),
+		residues:       make([][]S, chNum),
+		noResidueFlags: make([]bool, chNum),
+		noDecodeFlags:  make([]bool, 0, chNum),
+		submapVectors:  make([][]S, 0, chNum),
+	}
+	for ch := 0; ch < chNum; ch++ {
+		bd.floors[ch] = make([]int, half)
+		bd.residues[ch] = make([]S, half)
+	}
+	return bd
+}
+
+// readBlock decodes floors and residues of an audio packet, and undoes channel coupling.
+func (bd *blockDecoder[S]) readBlock(p *ogg.Packet) (_ audioBlock[S], err error) {
+	ident, vs := bd.ident, bd.setup
+
 	packetType, err := p.GetFlag()
 	if err != nil {
-		return nil, err
+		return
 	}
 	if packetType {
-		return nil, errors.New("invalid packet type flag")
+		err = errors.New("invalid packet type flag")
+		return
 	}
 	modeNum, err := p.GetUint(fls(len(vs.modeConfigs) - 1))
 	if err != nil {
-		return nil, err
+		return
+	}
+	if int(modeNum) >= len(vs.modeConfigs) {
+		err = errors.New("invalid mode number")
+		return
 	}
 	mode := vs.modeConfigs[modeNum]
 
-	var blockExp int
-	var windowFunc func(int, int) float64
+	var blockExp, leftExp, rightExp int
 
 	if mode.blockFlag { // long window
 		blockExp = int(ident.BlockExp[1])
-		windowFlags, err := p.GetUint(2)
+		var windowFlags uint32
+		windowFlags, err = p.GetUint(2)
 		if err != nil {
-			return nil, err
+			return
 		}
-		leftExp := int(ident.BlockExp[windowFlags&1])
-		rightExp := int(ident.BlockExp[(windowFlags>>1)&1])
-		windowFunc = transform.VorbisWindowVarWidth(leftExp, rightExp)
+		leftExp = int(ident.BlockExp[windowFlags&1])
+		rightExp = int(ident.BlockExp[(windowFlags>>1)&1])
 	} else {
 		blockExp = int(ident.BlockExp[0])
-		windowFunc = transform.VorbisWindowVarWidth(blockExp, blockExp)
+		leftExp, rightExp = blockExp, blockExp
 	}
 
 	mapping := vs.mappingConfigs[mode.mapping]
 	chNum := int(ident.Channels)
+	n := 1 << (blockExp - 1)
 
 	// floor decode
-	floors := make([][]int, chNum)
-	noResidueFlags := make([]bool, chNum)
+	floors := bd.floorViews
+	noResidueFlags := bd.noResidueFlags
 	for i := 0; i < chNum; i++ {
 		floor := vs.floorConfigs[mapping.submaps[mapping.mapMux[i]].floor]
 
-		floorShape, err := readFloorPacket(p, blockExp-1, floor, vs.codebooks)
+		used, err := readFloorPacket(p, floor, vs.codebooks, &bd.floorBuf, bd.floors[i][:n])
 		if err != nil && !errors.Is(err, ogg.ErrEndOfPacket) {
-			return nil, err
+			return audioBlock[S]{}, err
 		}
-		if floorShape == nil { // unused
-			noResidueFlags[i] = true
+		if used {
+			floors[i] = bd.floors[i][:n]
+		} else { // unused
+			floors[i] = nil
 		}
-		floors[i] = floorShape
+		noResidueFlags[i] = !used
 	}
 	// nonzero propagate
 	for _, v := range mapping.polarMap {
@@ -65,19 +140,269 @@ func readAudioPacket(p *ogg.Packet, ident Identification, vs VorbisSetup) ([][]f
 	}
 
 	// residue decode
+	residues := bd.residues
 	for i, submap := range mapping.submaps {
-		noDecodeFlags := make([]bool, 0, chNum)
+		noDecodeFlags := bd.noDecodeFlags[:0]
+		resVectors := bd.submapVectors[:0]
 		for ch, submapIndex := range mapping.mapMux {
 			if int(submapIndex) == i {
 				noDecodeFlags = append(noDecodeFlags, noResidueFlags[ch])
+				resVectors = append(resVectors, residues[ch])
 			}
 		}
 		residue := vs.residueConfigs[submap.residue]
 
-		resVectors, err := readResiduePacket(p, blockExp-1, residue, vs.codebooks, noDecodeFlags)
+		err := readResiduePacket(p, n, residue, vs.codebooks, noDecodeFlags, resVectors, &bd.residueBuf)
+		if err != nil {
+			return audioBlock[S]{}, err
+		}
+	}
+	for ch := range residues {
+		residues[ch] = residues[ch][:n]
+	}
+
+	// inverse coupling
+	for i := len(mapping.polarMap) - 1; i >= 0; i-- {
+		magnitude := residues[mapping.polarMap[i][0]]
+		angle := residues[mapping.polarMap[i][1]]
+		for j, m := range magnitude {
+			a := angle[j]
+			if m > 0 {
+				if a > 0 {
+					magnitude[j], angle[j] = m, m-a
+				} else {
+					magnitude[j], angle[j] = m+a, m
+				}
+			} else {
+				if a > 0 {
+					magnitude[j], angle[j] = m, m+a
+				} else {
+					magnitude[j], angle[j] = m-a, m
+				}
+			}
+		}
+	}
+
+	return audioBlock[S]{
+		blockExp: blockExp,
+		leftExp:  leftExp,
+		rightExp: rightExp,
+		floors:   floors,
+		residues: residues,
+	}, nil
+}
+
+// floatDecoder performs synthesis in floating point.
+type floatDecoder[F transform.Float] struct {
+	blockDecoder[F]
+	plans        [2]*transform.IMDCTPlan[F]
+	windows      map[[3]int][]F
+	spectra      [][]F
+	spectraViews [][]F
+	blocks       [][]F
+	views        [][]F
+}
+
+func newFloatDecoder[F transform.Float](ident Identification, vs VorbisSetup) *floatDecoder[F] {
+	chNum := int(ident.Channels)
+	d := &floatDecoder[F]{
+		blockDecoder: newBlockDecoder[F](ident, vs),
+		windows:      map[[3]int][]F{},
+		spectra:      make([][]F, chNum),
+		spectraViews: make([][]F, chNum),
+		blocks:       make([][]F, chNum),
+		views:        make([][]F, chNum),
+	}
+	for i, exp := range ident.BlockExp {
+		d.plans[i] = transform.NewIMDCTPlan[F](int(exp))
+	}
+	for ch := range d.blocks {
+		d.spectra[ch] = make([]F, 1<<(ident.BlockExp[1]-1))
+		d.blocks[ch] = make([]F, 1<<ident.BlockExp[1])
+	}
+	return d
+}
+
+func (d *floatDecoder[F]) decode(p *ogg.Packet) (block[F], error) {
+	b, err := d.readBlock(p)
+	if err != nil {
+		return block[F]{}, newAudioPacketError(p, err)
+	}
+
+	// dot product
+	n := 1 << b.blockExp
+	for ch := range d.spectra {
+		spectrum := d.spectra[ch][:n/2]
+		if b.floors[ch] != nil {
+			for i, y := range b.floors[ch] {
+				spectrum[i] = floorAmplitude[F](y) * b.residues[ch][i]
+			}
+		} else {
+			clear(spectrum)
+		}
+		d.spectraViews[ch] = spectrum
+	}
+	shape := [3]int{b.blockExp, b.leftExp, b.rightExp}
+	return block[F]{d.synthesize(d.spectraViews, shape), d.spectraViews, shape}, nil
+}
+
+func (d *floatDecoder[F]) synthesize(spectra [][]F, shape [3]int) [][]F {
+	n := 1 << shape[0]
+	plan := d.plans[0]
+	if shape[0] != int(d.ident.BlockExp[0]) {
+		plan = d.plans[1]
+	}
+	window, ok := d.windows[shape]
+	if !ok {
+		window = transform.WindowTable[F](transform.VorbisWindowVarWidth(shape[1], shape[2]), shape[0])
+		// transform.IMDCT is normalized by 4/N, which is compensated here.
+		// the scale is a power of 2, so that it is exact wherever applied.
+		scale := F(n) / 4
+		for i := range window {
+			window[i] *= scale
+		}
+		d.windows[shape] = window
+	}
+	for ch, spectrum := range spectra {
+		d.views[ch] = d.blocks[ch][:n]
+		plan.Transform(d.views[ch], spectrum, window)
+	}
+	return d.views
+}
+
+// fixedDecoder performs synthesis in fixed point.
+// Returned samples have sampleFracBits fractional bits.
+type fixedDecoder struct {
+	blockDecoder[int32]
+	plans        [2]*transform.IMDCTFixedPlan
+	windows      map[[3]int][]int32
+	spectra      [][]int32
+	spectraViews [][]int32
+	blocks       [][]int32
+	views        [][]int32
+}
+
+func newFixedDecoder(ident Identification, vs VorbisSetup) *fixedDecoder {
+	chNum := int(ident.Channels)
+	d := &fixedDecoder{
+		blockDecoder: newBlockDecoder[int32](ident, vs),
+		windows:      map[[3]int][]int32{},
+		spectra:      make([][]int32, chNum),
+		spectraViews: make([][]int32, chNum),
+		blocks:       make([][]int32, chNum),
+		views:        make([][]int32, chNum),
+	}
+	for i, exp := range ident.BlockExp {
+		d.plans[i] = transform.NewIMDCTFixedPlan(int(exp))
+	}
+	for ch := range d.blocks {
+		d.spectra[ch] = make([]int32, 1<<(ident.BlockExp[1]-1))
+		d.blocks[ch] = make([]int32, 1<<ident.BlockExp[1])
+	}
+	return d
+}
+
+func (d *fixedDecoder) decode(p *ogg.Packet) (block[int32], error) {
+	b, err := d.readBlock(p)
+	if err != nil {
+		return block[int32]{}, newAudioPacketError(p, err)
 	}
 
-	// TODO
+	n := 1 << b.blockExp
+	shift := floorFracBits + vqFracBits - sampleFracBits
+	for ch := range d.spectra {
+		spectrum := d.spectra[ch][:n/2]
+		if b.floors[ch] != nil {
+			for i, y := range b.floors[ch] {
+				spectrum[i] = transform.Saturate32(floorAmplitudeFixed(y) * int64(b.residues[ch][i]) >> shift)
+			}
+		} else {
+			clear(spectrum)
+		}
+		d.spectraViews[ch] = spectrum
+	}
+	shape := [3]int{b.blockExp, b.leftExp, b.rightExp}
+	return block[int32]{d.synthesize(d.spectraViews, shape), d.spectraViews, shape}, nil
+}
+
+func (d *fixedDecoder) synthesize(spectra [][]int32, shape [3]int) [][]int32 {
+	// IMDCTFixedPlan is not normalized, so that spectra need no scale.
+	n := 1 << shape[0]
+	plan := d.plans[0]
+	if shape[0] != int(d.ident.BlockExp[0]) {
+		plan = d.plans[1]
+	}
+	window, ok := d.windows[shape]
+	if !ok {
+		window = transform.WindowTableFixed(transform.VorbisWindowVarWidthFixed(shape[1], shape[2]), shape[0])
+		d.windows[shape] = window
+	}
+	for ch, spectrum := range spectra {
+		d.views[ch] = d.blocks[ch][:n]
+		plan.Transform(d.views[ch], spectrum, window)
+	}
+	return d.views
+}
+
+// overlapAdd writes finished samples from the center of previous block to the center of current block into dst.
+// prevTail is the latter half of previous block.
+func overlapAdd[S sample](prevTail, cur, dst []S) []S {
+	pn, cn := len(prevTail)*2, len(cur)
+	res := dst[:pn/4+cn/4]
+	for i := range res {
+		res[i] = 0
+		if i < len(prevTail) {
+			res[i] += prevTail[i]
+		}
+		if j := cn/4 - pn/4 + i; j >= 0 {
+			res[i] += cur[j]
+		}
+	}
+	return res
+}
+
+// overlapper overlaps and adds consecutive windowed blocks of all channels.
+type overlapper[S sample] struct {
+	tails    [][]S // latter half of the previous block
+	tailLen  int   // 0 before the first block
+	finished [][]S
+	chunk    [][]S
+}
+
+func newOverlapper[S sample](ident Identification) overlapper[S] {
+	chNum := int(ident.Channels)
+	half := 1 << (ident.BlockExp[1] - 1)
+	o := overlapper[S]{
+		tails:    make([][]S, chNum),
+		finished: make([][]S, chNum),
+		chunk:    make([][]S, chNum),
+	}
+	for ch := range o.tails {
+		o.tails[ch] = make([]S, half)
+		o.finished[ch] = make([]S, half)
+	}
+	return o
+}
+
+// add overlaps blocks with the previous ones, and returns finished samples valid until the next call.
+// It returns nil for the first block, which only primes overlap.
+func (o *overlapper[S]) add(blocks [][]S) [][]S {
+	n := len(blocks[0])
+	emitting := o.tailLen > 0
+	for ch, v := range blocks {
+		if emitting {
+			o.chunk[ch] = overlapAdd(o.tails[ch][:o.tailLen], v, o.finished[ch])
+		}
+		copy(o.tails[ch], v[n/2:])
+	}
+	o.tailLen = n / 2
+	if !emitting {
+		return nil
+	}
+	return o.chunk
+}
 
-	return nil, nil
+// reset forgets the previous block, so that the next block only primes overlap.
+func (o *overlapper[S]) reset() {
+	o.tailLen = 0
 }
@@ -0,0 +1,67 @@
+package vorbis
+
+import (
+	"testing"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+// steady-state decoding of packets reuses buffers of the decoder, and allocates nothing.
+func testDecodeAllocs[S sample, D packetDecoder[S]](t *testing.T, newDecoder func(Identification, VorbisSetup) D) {
+	vd := openTestDecoder(t)
+	if err := vd.ReadHeaders(); err != nil {
+		t.Fatal(err)
+	}
+	d := newDecoder(vd.Identification, vd.setup)
+	ov := newOverlapper[S](vd.Identification)
+	packets := vd.Packets[3:]
+	// a copy of packet is read, reset for each run
+	var p ogg.Packet
+	decodeAll := func() {
+		for _, packet := range packets {
+			p = packet
+			b, err := d.decode(&p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ov.add(b.samples)
+		}
+	}
+	// the first pass caches windows of each block size transition
+	decodeAll()
+	if n := testing.AllocsPerRun(5, decodeAll); n != 0 {
+		t.Errorf("%g allocations decoding %d packets", n, len(packets))
+	}
+}
+
+func TestDecodeAllocsFloat64(t *testing.T) {
+	testDecodeAllocs[float64](t, newFloatDecoder[float64])
+}
+
+func TestDecodeAllocsFloat32(t *testing.T) {
+	testDecodeAllocs[float32](t, newFloatDecoder[float32])
+}
+
+func TestDecodeAllocsFixed(t *testing.T) {
+	testDecodeAllocs[int32](t, newFixedDecoder)
+}
+
+func TestDecodePacketAllocs(t *testing.T) {
+	vd := openTestDecoder(t)
+	d, err := NewDecoder(vd.Packets[0].Bytes(), vd.Packets[1].Bytes(), vd.Packets[2].Bytes(), HeaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets := vd.Packets[3:]
+	decodeAll := func() {
+		for i := range packets {
+			if _, err := d.DecodePacket(packets[i].Bytes()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	decodeAll()
+	if n := testing.AllocsPerRun(5, decodeAll); n != 0 {
+		t.Errorf("%g allocations decoding %d packets", n, len(packets))
+	}
+}
@@ -2,21 +2,94 @@ package vorbis
 
 import (
 	"errors"
+	"fmt"
+	"math"
+
 	"github.com/sr8e/vorbis/huffman"
 	"github.com/sr8e/vorbis/ogg"
+	"github.com/sr8e/vorbis/transform"
 )
 
 type codebook struct {
 	decisionTree huffman.HuffmanTree
 	vqMap        vqLookup
+
+	// as read, for inspection
+	lengths []int // codeword length of each entry, -1 for unused
+	ordered bool
+	sparse  bool
 }
 
 type vqLookup struct {
-	dimension uint16
-	vectors   [][]float64
+	lookupType    uint8
+	dimension     uint16
+	entryLen      int
+	multiplicands []uint32
+	lookupValues  int
+	minimum       float64
+	delta         float64
+	fixedMin      int64
+	fixedDelta    int64
+	seqFlag       bool
+
+	// as read, for inspection
+	valueBits  uint8
+	rawMinimum uint32
+	rawDelta   uint32
+
+	// vectors expanded in advance, flattened by dimension. nil if computed on demand.
+	table []float64
+}
+
+// DefaultVQTableBudget is the default memory budget in bytes for VQ lookup tables expanded in advance.
+const DefaultVQTableBudget = 1 << 20
+
+// Limits bounds memory allocated on reading setup header of untrusted streams.
+// Zero fields take default values, and negative fields mean no limit.
+type Limits struct {
+	// MaxCodebookEntries limits the total number of entries of all codebooks.
+	MaxCodebookEntries int
+	// MaxVQValues limits the total number of VQ multiplicands of all codebooks, each of which takes 4 bytes.
+	MaxVQValues int
+}
+
+const (
+	DefaultMaxCodebookEntries = 1 << 20
+	DefaultMaxVQValues        = 1 << 22
+)
+
+// setupBudget is the allowance of Limits left while reading setup header. negative for no limit.
+type setupBudget struct {
+	entries int
+	values  int
 }
 
-func readCodebook(p *ogg.Packet) (_ codebook, err error) {
+func (l Limits) budget() setupBudget {
+	resolve := func(v, def int) int {
+		if v == 0 {
+			return def
+		}
+		return max(v, -1)
+	}
+	return setupBudget{
+		entries: resolve(l.MaxCodebookEntries, DefaultMaxCodebookEntries),
+		values:  resolve(l.MaxVQValues, DefaultMaxVQValues),
+	}
+}
+
+// take consumes n from the allowance rest, or returns error if it is exceeded.
+func take(rest *int, n int, what string) error {
+	if *rest < 0 {
+		return nil
+	}
+	if n > *rest {
+		return fmt.Errorf("%w: %s", ErrLimitExceeded, what)
+	}
+	*rest -= n
+	return nil
+}
+
+func readCodebook(p *ogg.Packet, budget *setupBudget) (_ codebook, err error) {
 	pattern, err := p.GetUint(24)
 	if err != nil {
 		return
@@ -34,12 +107,20 @@ func readCodebook(p *ogg.Packet) (_ codebook, err error) {
 	if err != nil {
 		return
 	}
+	if entryLen == 0 {
+		err = errors.New("codebook has no entries")
+		return
+	}
+	err = take(&budget.entries, int(entryLen), "codebook entries")
+	if err != nil {
+		return
+	}
 
-	entries, err := readCodebookEntries(p, entryLen)
+	entries, ordered, sparse, err := readCodebookEntries(p, entryLen)
 	if err != nil {
 		return
 	}
-	vq, err := readVQLookup(p, dim, entryLen)
+	vq, err := readVQLookup(p, dim, entryLen, budget)
 	if err != nil {
 		return
 	}
@@ -51,27 +132,41 @@ func readCodebook(p *ogg.Packet) (_ codebook, err error) {
 	return codebook{
 		decisionTree: tree,
 		vqMap:        vq,
+		lengths:      entries,
+		ordered:      ordered,
+		sparse:       sparse,
 	}, nil
 }
 
-func readCodebookEntries(p *ogg.Packet, entryLen uint32) ([]int, error) {
-	entries := make([]int, entryLen)
+// readCodebookEntries reads codeword lengths of entries, -1 for unused, and how they are encoded.
+func readCodebookEntries(p *ogg.Packet, entryLen uint32) (entries []int, ordered, sparse bool, err error) {
+	entries = make([]int, entryLen)
 
-	ordered, err := p.GetFlag()
+	ordered, err = p.GetFlag()
 	if err != nil {
-		return nil, err
+		return
 	}
 
 	if ordered {
-		curLen, err := p.GetUintAsInt(5)
+		var curLen int
+		curLen, err = p.GetUintAsInt(5)
 		if err != nil {
-			return nil, err
+			return
 		}
 		curLen += 1
 		for i := uint32(0); i < entryLen; {
-			num, err := p.GetUint(fls(int(entryLen - i)))
+			var num uint32
+			num, err = p.GetUint(fls(int(entryLen - i)))
 			if err != nil {
-				return nil, err
+				return
+			}
+			if num > entryLen-i {
+				err = errors.New("ordered codebook entries overflow")
+				return
+			}
+			if num > 0 && curLen > 32 {
+				err = errors.New("codeword length exceeds 32 bits")
+				return
 			}
 			for j := i; j < i+num; j++ {
 				entries[j] = curLen
@@ -79,44 +174,51 @@ func readCodebookEntries(p *ogg.Packet, entryLen uint32) ([]int, error) {
 			i += num
 			curLen++
 		}
-	} else {
-		sparse, err := p.GetFlag()
-		if err != nil {
-			return nil, err
-		}
-		for i := range entries {
-			if sparse {
-				used, err := p.GetFlag()
-				if err != nil {
-					return nil, err
-				}
-				if !used {
-					entries[i] = -1
-					continue
-				}
-			}
-			cwLen, err := p.GetUintAsInt(5)
+		return
+	}
+
+	sparse, err = p.GetFlag()
+	if err != nil {
+		return
+	}
+	for i := range entries {
+		if sparse {
+			var used bool
+			used, err = p.GetFlag()
 			if err != nil {
-				return nil, err
+				return
+			}
+			if !used {
+				entries[i] = -1
+				continue
 			}
-			entries[i] = cwLen + 1
 		}
+		var cwLen int
+		cwLen, err = p.GetUintAsInt(5)
+		if err != nil {
+			return
+		}
+		entries[i] = cwLen + 1
 	}
-	return entries, nil
+	return
 }
 
-func readVQLookup(p *ogg.Packet, dimension uint16, entryLen uint32) (_ vqLookup, err error) {
+func readVQLookup(p *ogg.Packet, dimension uint16, entryLen uint32, budget *setupBudget) (_ vqLookup, err error) {
 	lookup, err := p.GetUint(4)
 	if err != nil {
 		return
 	}
 	if lookup == 0 {
-		return
+		return vqLookup{dimension: dimension}, nil
 	}
 	if lookup > 2 {
 		err = errors.New("invalid VQ type")
 		return
 	}
+	if dimension == 0 {
+		err = errors.New("VQ codebook has zero dimension")
+		return
+	}
 
 	values, err := p.GetUintSerial(32, 32, 4, 1)
 	if err != nil {
@@ -133,6 +235,14 @@ func readVQLookup(p *ogg.Packet, dimension uint16, entryLen uint32) (_ vqLookup,
 	} else {
 		lookupLen = int(dimension) * int(entryLen)
 	}
+	err = take(&budget.values, lookupLen, "VQ values")
+	if err != nil {
+		return
+	}
+	if lookupLen*int(bits) > p.BitsLeft() {
+		err = ogg.ErrEndOfPacket
+		return
+	}
 	muls := make([]uint32, lookupLen)
 	for i := 0; i < lookupLen; i++ {
 		muls[i], err = p.GetUint(bits)
@@ -140,37 +250,98 @@ func readVQLookup(p *ogg.Packet, dimension uint16, entryLen uint32) (_ vqLookup,
 			return
 		}
 	}
-	vectors := make([][]float64, entryLen)
-	if lookup == 1 {
-		for i := range vectors {
-			var last float64
-			mulOfs := i
-			vectors[i] = make([]float64, dimension)
-			for j := range vectors[i] {
-				vectors[i][j] = float64(muls[mulOfs%lookupLen])*delta + minimum + last
-				if seqFlag {
-					last = vectors[i][j] // what tf is this for?
-				}
-				mulOfs /= lookupLen
-			}
+	return vqLookup{
+		lookupType:    uint8(lookup),
+		dimension:     dimension,
+		entryLen:      int(entryLen),
+		multiplicands: muls,
+		lookupValues:  lookupLen,
+		minimum:       minimum,
+		delta:         delta,
+		fixedMin:      clampFixed(toFixed(values[0], vqFracBits), vqFixedLimit),
+		fixedDelta:    clampFixed(toFixed(values[1], vqFracBits), math.MaxInt32),
+		seqFlag:       seqFlag,
+		valueBits:     uint8(bits),
+		rawMinimum:    values[0],
+		rawDelta:      values[1],
+	}, nil
+}
+
+// tableSize returns the memory in bytes required to expand all vectors.
+func (vq *vqLookup) tableSize() int {
+	return vq.entryLen * int(vq.dimension) * 8
+}
+
+// expand computes all vectors in advance if they fit in budget, and returns the remaining budget.
+func (vq *vqLookup) expand(budget int) int {
+	if vq.lookupType == 0 || vq.tableSize() > budget {
+		return budget
+	}
+	dim := int(vq.dimension)
+	table := make([]float64, vq.entryLen*dim)
+	for i := 0; i < vq.entryLen; i++ {
+		vq.computeVector(i, table[i*dim:(i+1)*dim])
+	}
+	vq.table = table
+	return budget - vq.tableSize()
+}
+
+// computeVector computes the vector of VQ lookup into v from multiplicands.
+func (vq *vqLookup) computeVector(index int, v []float64) {
+	var last float64
+	mulOfs := index
+	for j := range v {
+		var mul uint32
+		if vq.lookupType == 1 {
+			mul = vq.multiplicands[mulOfs%vq.lookupValues]
+			mulOfs /= vq.lookupValues
+		} else {
+			mul = vq.multiplicands[index*int(vq.dimension)+j]
 		}
-	} else {
-		for i := range vectors {
-			var last float64
-			vectors[i] = make([]float64, dimension)
-			for j := range vectors[i] {
-				vectors[i][j] = float64(muls[i*int(dimension)+j])*delta + minimum + last
-				if seqFlag {
-					last = vectors[i][j]
-				}
-			}
+		v[j] = float64(mul)*vq.delta + vq.minimum + last
+		if vq.seqFlag {
+			last = v[j]
 		}
 	}
+}
 
-	return vqLookup{
-		dimension: dimension,
-		vectors:   vectors,
-	}, nil
+// vector returns the vector of VQ lookup, from the expanded table if available, or computed into buf.
+func (vq *vqLookup) vector(index int, buf []float64) []float64 {
+	dim := int(vq.dimension)
+	if vq.table != nil {
+		return vq.table[index*dim : (index+1)*dim]
+	}
+	vq.computeVector(index, buf[:dim])
+	return buf[:dim]
+}
+
+// vqFixedLimit bounds each term of VQ vectors in fixed point, far beyond the range of output.
+const vqFixedLimit = 1 << 40
+
+// clampFixed clamps v into [-limit, limit].
+func clampFixed(v, limit int64) int64 {
+	return max(min(v, limit), -limit)
+}
+
+// fixedVector computes the vector of VQ lookup into v in fixed point with vqFracBits fractional bits.
+func (vq *vqLookup) fixedVector(index int, v []int32) {
+	var last int64
+	mulOfs := index
+	for j := range v {
+		var mul uint32
+		if vq.lookupType == 1 {
+			mul = vq.multiplicands[mulOfs%vq.lookupValues]
+			mulOfs /= vq.lookupValues
+		} else {
+			mul = vq.multiplicands[index*int(vq.dimension)+j]
+		}
+		// every term is within vqFixedLimit, so that the sum never overflows
+		val := clampFixed(int64(mul)*vq.fixedDelta, vqFixedLimit) + vq.fixedMin + last
+		v[j] = transform.Saturate32(val)
+		if vq.seqFlag {
+			last = clampFixed(val, vqFixedLimit)
+		}
+	}
 }
 
 func (cb *codebook) readValue(p *ogg.Packet) (int, error) {
@@ -189,20 +360,31 @@ func (cb *codebook) readValue(p *ogg.Packet) (int, error) {
 
 // ReadScalarValue reads bits from packet until it encounters leaf node in decision tree and returns scalar value.
 func (cb *codebook) ReadScalarValue(p *ogg.Packet) (int, error) {
-	if cb.vqMap.dimension != 0 {
-		return 0, errors.New("cannot read scalar value from vector context")
-	}
 	return cb.readValue(p)
 }
 
 // ReadVectorValue reads bits from packet until it encounters leaf node in decision tree and returns vector value from VQ lookup table.
-func (cb *codebook) ReadVectorValue(p *ogg.Packet) ([]float64, error) {
-	if cb.vqMap.dimension == 0 {
+// buf is used to hold the vector unless the table is expanded, and must be as long as the dimension.
+func (cb *codebook) ReadVectorValue(p *ogg.Packet, buf []float64) ([]float64, error) {
+	if cb.vqMap.lookupType == 0 {
 		return nil, errors.New("cannot read vector value from scalar context")
 	}
 	vqIndex, err := cb.readValue(p)
 	if err != nil {
 		return nil, err
 	}
-	return cb.vqMap.vectors[vqIndex], nil
+	return cb.vqMap.vector(vqIndex, buf), nil
+}
+
+// ReadFixedVectorValue is the same as ReadVectorValue, but writes vector into v in fixed point.
+func (cb *codebook) ReadFixedVectorValue(p *ogg.Packet, v []int32) error {
+	if cb.vqMap.lookupType == 0 {
+		return errors.New("cannot read vector value from scalar context")
+	}
+	vqIndex, err := cb.readValue(p)
+	if err != nil {
+		return err
+	}
+	cb.vqMap.fixedVector(vqIndex, v)
+	return nil
 }
@@ -1,16 +1,33 @@
 package vorbis
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
 	"github.com/sr8e/vorbis/ogg"
+	"github.com/sr8e/vorbis/transform"
 )
 
+// VorbisDecoder decodes a Vorbis stream in Ogg, built on Decoder.
 type VorbisDecoder struct {
-	Packets        []ogg.Packet
-	Identification Identification
-	setup          VorbisSetup
-	isReady        bool
+	Decoder
+	HeaderOptions
+	Packets []ogg.Packet
+	isReady bool
 }
 
+// Concealment is the method to substitute malformed or lost audio packets.
+type Concealment int
+
+const (
+	ConcealNone    Concealment = iota
+	ConcealSilence             // substitute silent block
+	ConcealRepeat              // repeat the spectrum of the previous block, fading out by half each time
+)
+
 type Identification struct {
 	Channels   byte
 	SampleRate uint32
@@ -18,6 +35,12 @@ type Identification struct {
 	BlockExp   [2]uint8
 }
 
+// Comment is the content of comment header.
+type Comment struct {
+	Vendor   string
+	Comments []string // "NAME=value" pairs, as is
+}
+
 type VorbisSetup struct {
 	codebooks      []codebook
 	floorConfigs   []floorConfig
@@ -31,7 +54,132 @@ type modeConfig struct {
 	mapping   uint8
 }
 
+// NewVorbisDecoder reads Ogg bitstream from r, and returns the decoder of the first Vorbis logical stream in it.
+func NewVorbisDecoder(r io.Reader) (*VorbisDecoder, error) {
+	var ol ogg.OggLoader
+	err := ol.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	err = ol.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	serials := make([]uint32, 0, len(ol.Streams))
+	for serial := range ol.Streams {
+		serials = append(serials, serial)
+	}
+	slices.Sort(serials)
+
+	err = ErrNotVorbis
+	for _, serial := range serials {
+		s := ol.Streams[serial]
+		packets, streamErr := s.GetPackets()
+		if streamErr != nil {
+			err = streamErr
+			continue
+		}
+		if len(packets) < 3 {
+			continue
+		}
+		head := packets[0]
+		if readCommonHeader(&head, 0) == nil {
+			return &VorbisDecoder{Packets: packets}, nil
+		}
+	}
+	return nil, err
+}
+
+// DecodeFollow decodes the first Vorbis stream read from ol as its packets are completed,
+// and passes finished samples to yield, which are valid only during the call.
+// With ol in follow mode, it keeps decoding data appended to the source until the end of streams,
+// or returns ctx.Err() when ctx is done.
+func (vd *VorbisDecoder) DecodeFollow(ctx context.Context, ol *ogg.OggLoader, yield func([][]float64) error) error {
+	// reading is stopped on return, which may be waiting in another goroutine
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var serial uint32
+	found := false
+	headers := make([]ogg.Packet, 0, 3)
+	for len(headers) < 3 {
+		s, p, err := ol.NextPacket(ctx)
+		if errors.Is(err, io.EOF) {
+			return ErrNotVorbis
+		}
+		if err != nil {
+			return err
+		}
+		if !found {
+			head := p
+			if readCommonHeader(&head, 0) != nil {
+				continue
+			}
+			serial, found = s, true
+		}
+		if s == serial {
+			headers = append(headers, p)
+		}
+	}
+	vd.Packets = headers
+	err := vd.ReadHeaders()
+	if err != nil {
+		return err
+	}
+
+	next := func(ctx context.Context) (ogg.Packet, error) {
+		for {
+			s, p, err := ol.NextPacket(ctx)
+			if err != nil || s == serial {
+				return p, err
+			}
+		}
+	}
+	if !vd.FixedPoint {
+		return decodeStream(ctx, &vd.Decoder, next, newFloatDecoder[float64], yield)
+	}
+	converted := make([][]float64, vd.Identification.Channels)
+	return decodeStream(ctx, &vd.Decoder, next, newFixedDecoder, func(chunk [][]int32) error {
+		for ch, v := range chunk {
+			converted[ch] = grow(converted[ch], len(v))
+			for i, val := range v {
+				converted[ch][i] = fixedToFloat[float64](val)
+			}
+		}
+		return yield(converted)
+	})
+}
+
+// DecodeAll decodes all audio packets and returns samples for each channel.
 func (vd *VorbisDecoder) DecodeAll() ([][]float64, error) {
+	if vd.FixedPoint {
+		return decodeAllFixedAsFloat[float64](vd)
+	}
+	return decodeAll(vd, newFloatDecoder[float64])
+}
+
+// DecodeAllFloat32 is the same as DecodeAll, but synthesis is performed in float32:
+// residue, coupling, the floor product, IMDCT and overlap-add.
+// VQ vectors and the inverse dB table of floor are computed in float64, and rounded to float32 once when read.
+func (vd *VorbisDecoder) DecodeAllFloat32() ([][]float32, error) {
+	if vd.FixedPoint {
+		return decodeAllFixedAsFloat[float32](vd)
+	}
+	return decodeAll(vd, newFloatDecoder[float32])
+}
+
+// DecodeAllInt16 decodes all audio packets into 16 bit PCM samples, clipped at full scale.
+func (vd *VorbisDecoder) DecodeAllInt16() ([][]int16, error) {
+	return decodeAllInt(vd, 16, func(v int32) int16 { return int16(v) })
+}
+
+// DecodeAllInt32 decodes all audio packets into 32 bit PCM samples, clipped at full scale.
+func (vd *VorbisDecoder) DecodeAllInt32() ([][]int32, error) {
+	return decodeAllInt(vd, 32, func(v int32) int32 { return v })
+}
+
+func decodeAll[S sample, D packetDecoder[S]](vd *VorbisDecoder, newDecoder func(Identification, VorbisSetup) D) ([][]S, error) {
 	if !vd.isReady {
 		err := vd.ReadHeaders()
 		if err != nil {
@@ -39,39 +187,298 @@ func (vd *VorbisDecoder) DecodeAll() ([][]float64, error) {
 		}
 	}
 
-	samples := make([][]float64, vd.Identification.Channels)
+	chNum := int(vd.Identification.Channels)
+	samples := make([][]S, chNum)
 	for ch := range samples {
-		samples[ch] = make([]float64, 0)
+		samples[ch] = make([]S, 0)
 	}
 
-	for _, packet := range vd.Packets[3:] {
-		content, err := readAudioPacket(&packet, vd.Identification, vd.setup)
-		if err != nil {
-			return nil, err
+	packets := vd.Packets[3:]
+	next := func(context.Context) (ogg.Packet, error) {
+		if len(packets) == 0 {
+			return ogg.Packet{}, io.EOF
 		}
-
-		for ch, v := range content {
+		p := packets[0]
+		packets = packets[1:]
+		return p, nil
+	}
+	err := decodeStream(context.Background(), &vd.Decoder, next, newDecoder, func(chunk [][]S) error {
+		for ch, v := range chunk {
 			samples[ch] = append(samples[ch], v...)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
 	return samples, nil
 }
 
-func (vd *VorbisDecoder) ReadHeaders() error {
-	ident, err := readIdentification(&vd.Packets[0])
+// decodeStream decodes audio packets returned by next until io.EOF, and passes finished samples to emit.
+// Samples passed to emit are valid only during the call. ctx is passed to next.
+func decodeStream[S sample, D packetDecoder[S]](ctx context.Context, d *Decoder, next func(context.Context) (ogg.Packet, error), newDecoder func(Identification, VorbisSetup) D, emit func([][]S) error) error {
+	newPacketDecoder := func() packetDecoder[S] {
+		return newDecoder(d.Identification, d.setup)
+	}
+	sq := newSequencer[S](d.Identification, d.Concealment, newPacketDecoder)
+	sq.onConceal = d.OnConceal
+	err := decodeBlocks(ctx, next, d.Parallelism, newPacketDecoder, func(res decodedPacket[S]) error {
+		return sq.push(res, emit)
+	})
 	if err != nil {
 		return err
 	}
-	vd.Identification = ident
+	return sq.finish(emit)
+}
 
-	// TODO read comment header
+// sequencer overlaps blocks of consecutive packets into finished samples,
+// and substitutes blocks for malformed or lost packets as concealment selects.
+// The first audio packet only primes overlap, and finishes no sample.
+// So does the packet after lost ones without concealment, since the previous block is unknown.
+type sequencer[S sample] struct {
+	concealment Concealment
+	onConceal   func(start, length int)
+	blockExp    [2]int
+	ov          overlapper[S]
+	total       int   // number of samples finished for each channel
+	offset      int64 // granule position of the first sample finished
+	end         int   // number of samples the stream ends at, -1 if unknown
+
+	// states for concealment
+	synth        packetDecoder[S] // synthesizes substituted blocks
+	prev         [][]S            // spectra of the previous block
+	substituted  [][]S
+	prevShape    [3]int
+	hasPrev      bool
+	pending      bool // a block is substituted before the next one, whose shape is not known yet
+	concealStart int  // start of samples affected by substituted blocks, -1 if none
+}
+
+func newSequencer[S sample](ident Identification, concealment Concealment, newDecoder func() packetDecoder[S]) *sequencer[S] {
+	sq := &sequencer[S]{
+		concealment:  concealment,
+		blockExp:     [2]int{int(ident.BlockExp[0]), int(ident.BlockExp[1])},
+		ov:           newOverlapper[S](ident),
+		end:          -1,
+		concealStart: -1,
+	}
+	if concealment != ConcealNone {
+		chNum := int(ident.Channels)
+		half := 1 << (ident.BlockExp[1] - 1)
+		sq.synth = newDecoder()
+		sq.prev = make([][]S, chNum)
+		sq.substituted = make([][]S, chNum)
+		for ch := range sq.prev {
+			sq.prev[ch] = make([]S, half)
+			sq.substituted[ch] = make([]S, half)
+		}
+	}
+	return sq
+}
 
-	vs, err := readSetup(&vd.Packets[2], ident)
+// push passes samples finished by the decoded packet to emit, preceded by those of substituted blocks.
+// Error of the packet is returned unless concealed.
+func (sq *sequencer[S]) push(res decodedPacket[S], emit func([][]S) error) error {
+	if res.discontinuous {
+		if sq.concealment == ConcealNone {
+			sq.ov.reset()
+		} else if err := sq.lose(emit); err != nil {
+			// the number of lost packets is unknown, so that one block is substituted
+			return err
+		}
+	}
+	if res.err != nil {
+		if sq.concealment == ConcealNone {
+			return res.err
+		}
+		return sq.lose(emit)
+	}
+	b := res.block
+	if len(b.samples) == 0 {
+		return nil
+	}
+	if sq.pending {
+		// the substituted block fits the window of the previous block to the next one
+		if err := sq.substitute(b.shape[1], emit); err != nil {
+			return err
+		}
+	}
+	if res.hasGranule && res.last {
+		// the granule position of the last page may trim the last block
+		sq.end = int(int64(res.granule) - sq.offset)
+	}
+	if err := sq.overlap(b.samples, emit); err != nil {
+		return err
+	}
+	if res.hasGranule && !res.last {
+		// positions are taken again, since the stream may start at any position and lose packets
+		sq.offset = int64(res.granule) - int64(sq.total)
+	}
+	if sq.prev != nil {
+		sq.keep(b.spectra, b.shape)
+	}
+	if sq.concealStart >= 0 {
+		sq.report()
+	}
+	return nil
+}
+
+// finish substitutes the block left pending at the end of stream, and reports concealment.
+func (sq *sequencer[S]) finish(emit func([][]S) error) error {
+	if sq.pending {
+		if err := sq.substitute(-1, emit); err != nil {
+			return err
+		}
+	}
+	if sq.concealStart >= 0 {
+		sq.report()
+	}
+	return nil
+}
+
+// reset forgets the previous block.
+func (sq *sequencer[S]) reset() {
+	sq.ov.reset()
+	sq.hasPrev = false
+	sq.pending = false
+}
+
+// lose marks a block to be substituted. A block already pending is substituted first.
+func (sq *sequencer[S]) lose(emit func([][]S) error) error {
+	if !sq.hasPrev { // nothing to substitute before the first block
+		return nil
+	}
+	if sq.pending {
+		if err := sq.substitute(-1, emit); err != nil {
+			return err
+		}
+	}
+	sq.pending = true
+	return nil
+}
+
+// substitute overlaps a block repeating the spectrum of the previous one, or a silent block.
+// The window slopes to the previous block, and to the next one unless rightExp is negative,
+// in which case the block has the slope of its own size.
+func (sq *sequencer[S]) substitute(rightExp int, emit func([][]S) error) error {
+	sq.pending = false
+	leftExp := sq.prevShape[2]
+	// a short block has short slopes, otherwise the lost block is assumed to be the same size as the previous one
+	blockExp := sq.prevShape[0]
+	if leftExp == sq.blockExp[1] || rightExp == sq.blockExp[1] {
+		blockExp = sq.blockExp[1]
+	}
+	if rightExp < 0 {
+		rightExp = blockExp
+	}
+	shape := [3]int{blockExp, leftExp, rightExp}
+
+	srcLen, dstLen := 1<<(sq.prevShape[0]-1), 1<<(blockExp-1)
+	for ch, src := range sq.prev {
+		dst := sq.substituted[ch][:dstLen]
+		if sq.concealment == ConcealRepeat {
+			// the nearest bin of the same frequency, faded out by half
+			for j := range dst {
+				dst[j] = src[j*srcLen/dstLen] / 2
+			}
+		} else {
+			clear(dst)
+		}
+		sq.substituted[ch] = dst
+	}
+	if sq.concealStart < 0 {
+		sq.concealStart = sq.total
+	}
+	if err := sq.overlap(sq.synth.synthesize(sq.substituted, shape), emit); err != nil {
+		return err
+	}
+	sq.keep(sq.substituted, shape)
+	return nil
+}
+
+// keep copies the spectra of the block just overlapped, to be repeated.
+func (sq *sequencer[S]) keep(spectra [][]S, shape [3]int) {
+	for ch, v := range spectra {
+		sq.prev[ch] = sq.prev[ch][:len(v)]
+		copy(sq.prev[ch], v)
+	}
+	sq.prevShape = shape
+	sq.hasPrev = true
+}
+
+func (sq *sequencer[S]) overlap(samples [][]S, emit func([][]S) error) error {
+	chunk := sq.ov.add(samples)
+	if chunk == nil {
+		return nil
+	}
+	n := len(chunk[0])
+	if sq.end >= 0 && sq.total+n > sq.end {
+		n = max(sq.end-sq.total, 0)
+		for ch := range chunk {
+			chunk[ch] = chunk[ch][:n]
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+	sq.total += n
+	return emit(chunk)
+}
+
+func (sq *sequencer[S]) report() {
+	if sq.onConceal != nil && sq.total > sq.concealStart {
+		sq.onConceal(sq.concealStart, sq.total-sq.concealStart)
+	}
+	sq.concealStart = -1
+}
+
+func decodeAllFixedAsFloat[F transform.Float](vd *VorbisDecoder) ([][]F, error) {
+	fixed, err := decodeAll(vd, newFixedDecoder)
 	if err != nil {
+		return nil, err
+	}
+	return convertSamples(fixed, fixedToFloat[F]), nil
+}
+
+// decodeAllInt decodes samples into signed integer of given bit width, by the synthesis FixedPoint selects.
+func decodeAllInt[I int16 | int32](vd *VorbisDecoder, bits int, conv func(int32) I) ([][]I, error) {
+	if vd.FixedPoint {
+		fixed, err := decodeAll(vd, newFixedDecoder)
+		if err != nil {
+			return nil, err
+		}
+		return convertSamples(fixed, func(v int32) I {
+			return conv(fixedToInt(v, bits))
+		}), nil
+	}
+
+	floats, err := decodeAll(vd, newFloatDecoder[float64])
+	if err != nil {
+		return nil, err
+	}
+	return convertSamples(floats, func(v float64) I {
+		return conv(floatToInt(v, bits))
+	}), nil
+}
+
+func convertSamples[S, T any](samples [][]S, conv func(S) T) [][]T {
+	res := make([][]T, len(samples))
+	for ch, v := range samples {
+		res[ch] = make([]T, len(v))
+		for i, val := range v {
+			res[ch][i] = conv(val)
+		}
+	}
+	return res
+}
+
+func (vd *VorbisDecoder) ReadHeaders() error {
+	if len(vd.Packets) < 3 {
+		return fmt.Errorf("%w: header packets are missing", ErrNotVorbis)
+	}
+	if err := vd.readHeaders(vd.Packets[:3], vd.HeaderOptions); err != nil {
 		return err
 	}
-	vd.setup = vs
 	vd.isReady = true
 
 	return nil
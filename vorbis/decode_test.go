@@ -0,0 +1,171 @@
+package vorbis
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+func openTestDecoder(t testing.TB) *VorbisDecoder {
+	t.Helper()
+	f, err := os.Open("../testdata/test.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	vd, err := NewVorbisDecoder(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vd
+}
+
+func TestDecodeAllFloat32(t *testing.T) {
+	vd := openTestDecoder(t)
+	ref, err := vd.DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := vd.DecodeAllFloat32()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(ref) {
+		t.Fatalf("got %d channels, want %d", len(got), len(ref))
+	}
+	for ch := range ref {
+		if len(got[ch]) != len(ref[ch]) {
+			t.Fatalf("channel %d: got %d samples, want %d", ch, len(got[ch]), len(ref[ch]))
+		}
+		maxDiff := 0.0
+		for i, v := range ref[ch] {
+			maxDiff = math.Max(maxDiff, math.Abs(float64(got[ch][i])-v))
+		}
+		// a few ulp of float32 at full scale
+		if maxDiff > 1e-5 {
+			t.Errorf("channel %d: float32 differs from float64 by %g", ch, maxDiff)
+		}
+	}
+}
+
+func TestConceal(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		concealment Concealment
+		lost        int    // index of audio packet corrupted
+		shape       [3]int // of the lost block, which is substituted
+	}{
+		{"repeat long", ConcealRepeat, 25, [3]int{11, 11, 11}},
+		{"repeat long after short", ConcealRepeat, 3, [3]int{11, 8, 11}},
+		{"silence short", ConcealSilence, 8, [3]int{8, 8, 8}},
+		{"silence long before short", ConcealSilence, 28, [3]int{11, 11, 8}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			vd := openTestDecoder(t)
+			ref, err := vd.DecodeAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// blocks are decoded one by one, and the lost one is synthesized from the previous spectra
+			d := newFloatDecoder[float64](vd.Identification, vd.setup)
+			ov := newOverlapper[float64](vd.Identification)
+			want := make([][]float64, vd.Identification.Channels)
+			var prev [][]float64
+			start, length := -1, 0
+			for i, p := range vd.Packets[3:] {
+				var samples [][]float64
+				if i == c.lost {
+					spectra := make([][]float64, len(prev))
+					for ch, src := range prev {
+						spectra[ch] = make([]float64, 1<<(c.shape[0]-1))
+						if c.concealment == ConcealRepeat {
+							for j := range spectra[ch] {
+								spectra[ch][j] = src[j*len(src)/len(spectra[ch])] / 2
+							}
+						}
+					}
+					start = len(want[0])
+					samples = d.synthesize(spectra, c.shape)
+				} else {
+					b, err := d.decode(&p)
+					if err != nil {
+						t.Fatal(err)
+					}
+					prev = make([][]float64, len(b.spectra))
+					for ch, v := range b.spectra {
+						prev[ch] = slices.Clone(v)
+					}
+					samples = b.samples
+				}
+				for ch, v := range ov.add(samples) {
+					want[ch] = append(want[ch], v...)
+				}
+				if i == c.lost+1 {
+					length = len(want[0]) - start
+				}
+			}
+			// the end is trimmed by the granule position of the last page
+			if len(want[0]) < len(ref[0]) {
+				t.Fatalf("%d samples with the lost block, want at least %d", len(want[0]), len(ref[0]))
+			}
+			for ch := range want {
+				want[ch] = want[ch][:len(ref[ch])]
+			}
+
+			vd = openTestDecoder(t)
+			corrupted := bytes.Clone(vd.Packets[3+c.lost].Bytes())
+			corrupted[0] |= 1 // not an audio packet
+			vd.Packets[3+c.lost] = ogg.NewPacket(corrupted)
+			vd.Concealment = c.concealment
+			var reported [][2]int
+			vd.OnConceal = func(start, length int) {
+				reported = append(reported, [2]int{start, length})
+			}
+			got, err := vd.DecodeAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r := [][2]int{{start, length}}; !slices.Equal(reported, r) {
+				t.Errorf("concealed %v, want %v", reported, r)
+			}
+			for ch := range want {
+				if len(got[ch]) != len(want[ch]) {
+					t.Fatalf("channel %d: got %d samples, want %d", ch, len(got[ch]), len(want[ch]))
+				}
+				for i, v := range want[ch] {
+					if math.Abs(got[ch][i]-v) > 1e-12 {
+						t.Fatalf("channel %d: sample %d is %g, want %g", ch, i, got[ch][i], v)
+					}
+				}
+			}
+		})
+	}
+}
+
+// the end of stream is trimmed by the granule position of the last page.
+func TestDecodeAllLength(t *testing.T) {
+	vd := openTestDecoder(t)
+	last := vd.Packets[len(vd.Packets)-1]
+	granule, ok := last.Granule()
+	if !ok || !last.EndOfStream() {
+		t.Fatal("the last packet does not end the stream")
+	}
+	if granule != 44100 {
+		t.Fatalf("got granule position %d of the last page, want 44100", granule)
+	}
+	for _, fixedPoint := range []bool{false, true} {
+		vd.FixedPoint = fixedPoint
+		samples, err := vd.DecodeAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(samples[0]) != int(granule) {
+			t.Errorf("fixed point %t: got %d samples, want %d", fixedPoint, len(samples[0]), granule)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package vorbis
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+var (
+	ErrNotVorbis        = errors.New("not a vorbis stream")
+	ErrUnsupportedFloor = errors.New("floor type 0 is not supported")
+	ErrLimitExceeded    = errors.New("resource limit exceeded")
+)
+
+// HeaderError reports an error in header packets.
+type HeaderError struct {
+	Serial     uint32 // serial of the stream
+	PageOffset int64  // byte offset of the page where the packet begins
+	Packet     int    // 0 for identification, 1 for comment and 2 for setup header
+	BitOffset  int    // offset in the packet where the error is found
+	Section    string // section of the header being parsed, such as "codebook 3"
+	Err        error
+}
+
+func (e *HeaderError) Error() string {
+	return fmt.Sprintf("header packet %d of stream %08x (page at offset %d), %s at bit %d: %v",
+		e.Packet, e.Serial, e.PageOffset, e.Section, e.BitOffset, e.Err)
+}
+
+func (e *HeaderError) Unwrap() error {
+	return e.Err
+}
+
+// AudioPacketError reports an error in an audio packet.
+type AudioPacketError struct {
+	Serial     uint32 // serial of the stream
+	PageOffset int64  // byte offset of the page where the packet begins
+	Packet     int    // index of the packet in stream
+	BitOffset  int    // offset in the packet where the error is found
+	Err        error
+}
+
+func (e *AudioPacketError) Error() string {
+	return fmt.Sprintf("audio packet %d of stream %08x (page at offset %d) at bit %d: %v",
+		e.Packet, e.Serial, e.PageOffset, e.BitOffset, e.Err)
+}
+
+func (e *AudioPacketError) Unwrap() error {
+	return e.Err
+}
+
+func newHeaderError(p *ogg.Packet, packet int, section string, err error) error {
+	return &HeaderError{
+		Serial:     p.Serial(),
+		PageOffset: p.PageOffset(),
+		Packet:     packet,
+		BitOffset:  p.BitOffset(),
+		Section:    section,
+		Err:        err,
+	}
+}
+
+func newAudioPacketError(p *ogg.Packet, err error) error {
+	return &AudioPacketError{
+		Serial:     p.Serial(),
+		PageOffset: p.PageOffset(),
+		Packet:     p.Index(),
+		BitOffset:  p.BitOffset(),
+		Err:        err,
+	}
+}
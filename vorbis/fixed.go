@@ -0,0 +1,113 @@
+package vorbis
+
+import (
+	"math"
+
+	"github.com/sr8e/vorbis/transform"
+)
+
+// Fixed point decoding represents values as integers with implicit fractional bits.
+// VQ lookup values (and thus residue vectors) have vqFracBits, floor amplitudes are Q30,
+// and spectra and time domain samples have sampleFracBits.
+const (
+	vqFracBits     = 16
+	floorFracBits  = 30
+	sampleFracBits = 24
+)
+
+// inverseDBTableFixed is inverseDBTable in Q30.
+var inverseDBTableFixed = [0x100]int32{
+	114, 122, 130, 138, 147, 157, 167, 178,
+	189, 202, 215, 229, 243, 259, 276, 294,
+	313, 333, 355, 378, 403, 429, 457, 487,
+	518, 552, 588, 626, 667, 710, 756, 805,
+	858, 913, 973, 1036, 1103, 1175, 1251, 1332,
+	1419, 1511, 1609, 1714, 1825, 1944, 2070, 2205,
+	2348, 2501, 2663, 2836, 3021, 3217, 3426, 3649,
+	3886, 4138, 4407, 4694, 4999, 5324, 5670, 6038,
+	6430, 6848, 7293, 7767, 8272, 8810, 9382, 9992,
+	10641, 11333, 12069, 12854, 13689, 14578, 15526, 16535,
+	17609, 18754, 19972, 21270, 22653, 24125, 25692, 27362,
+	29140, 31034, 33051, 35199, 37486, 39922, 42516, 45279,
+	48222, 51356, 54693, 58247, 62032, 66064, 70357, 74929,
+	79798, 84984, 90507, 96388, 102652, 109323, 116428, 123994,
+	132052, 140633, 149772, 159505, 169871, 180910, 192666, 205187,
+	218521, 232722, 247846, 263952, 281105, 299373, 318828, 339547,
+	361613, 385112, 410139, 436792, 465177, 495407, 527602, 561888,
+	598403, 637290, 678705, 722811, 769784, 819808, 873084, 929822,
+	990247, 1054599, 1123133, 1196120, 1273851, 1356633, 1444795, 1538686,
+	1638678, 1745169, 1858579, 1979360, 2107990, 2244979, 2390871, 2546243,
+	2711712, 2887935, 3075609, 3275479, 3488338, 3715030, 3956454, 4213567,
+	4487388, 4779004, 5089570, 5420319, 5772562, 6147696, 6547208, 6972682,
+	7425806, 7908377, 8422308, 8969637, 9552535, 10173312, 10834431, 11538514,
+	12288351, 13086918, 13937379, 14843109, 15807698, 16834971, 17929002, 19094130,
+	20334974, 21656455, 23063814, 24562630, 26158848, 27858798, 29669219, 31597292,
+	33650663, 35837472, 38166393, 40646660, 43288110, 46101215, 49097132, 52287740,
+	55685692, 59304462, 63158400, 67262789, 71633904, 76289079, 81246773, 86526646,
+	92149635, 98138038, 104515600, 111307613, 118541009, 126244472, 134448549, 143185773,
+	152490792, 162400503, 172954203, 184193742, 196163689, 208911511, 222487758, 236946266,
+	252344370, 268743129, 286207572, 304806953, 324615027, 345710341, 368176547, 392102733,
+	417583779, 444720726, 473621185, 504399758, 537178497, 572087383, 609264845, 648858308,
+	691024778, 735931462, 783756436, 834689345, 888932163, 946699984, 1008221884, 1073741824,
+}
+
+// toFixed converts vorbis float format into fixed point value with fracBits fractional bits.
+func toFixed(v uint32, fracBits int) int64 {
+	frac := int64(v & 0x1fffff)
+	shift := int((v>>21)&0x3ff) - 788 + fracBits
+
+	var abs int64
+	if shift >= 0 {
+		if shift > 41 { // 21 bits mantissa would overflow
+			abs = math.MaxInt64 >> 1
+		} else {
+			abs = frac << shift
+		}
+	} else if shift > -22 {
+		abs = (frac + 1<<(-shift-1)) >> -shift
+	}
+
+	if (v>>31)&1 == 0 {
+		return abs
+	}
+	return -abs
+}
+
+// floorAmplitudeFixed is the Q30 counterpart of floorAmplitude.
+func floorAmplitudeFixed(index int) int64 {
+	if index < 0 || index > 0xff {
+		return 0
+	}
+	return int64(inverseDBTableFixed[index])
+}
+
+// fixedToFloat converts fixed point samples into floating point.
+func fixedToFloat[F transform.Float](v int32) F {
+	return F(v) / (1 << sampleFracBits)
+}
+
+// fixedToInt converts fixed point sample into full scale signed integer of given bit width, with rounding.
+func fixedToInt(v int32, bits int) int32 {
+	shift := sampleFracBits - (bits - 1)
+	var wide int64
+	if shift > 0 {
+		wide = (int64(v) + 1<<(shift-1)) >> shift
+	} else {
+		wide = int64(v) << -shift
+	}
+	return clip(wide, bits)
+}
+
+// floatToInt converts floating point sample into full scale signed integer of given bit width, with rounding.
+func floatToInt(v float64, bits int) int32 {
+	scaled := math.Round(v * float64(int64(1)<<(bits-1)))
+	if scaled >= math.MaxInt64 || scaled <= math.MinInt64 {
+		return clip(int64(math.Copysign(math.MaxInt32, scaled)), bits)
+	}
+	return clip(int64(scaled), bits)
+}
+
+func clip(v int64, bits int) int32 {
+	maxVal := int64(1)<<(bits-1) - 1
+	return int32(max(-maxVal-1, min(maxVal, v)))
+}
@@ -0,0 +1,87 @@
+package vorbis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDecodeFixedPoint(t *testing.T) {
+	vd := openTestDecoder(t)
+	ref, err := vd.DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vd.FixedPoint = true
+	got, err := vd.DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ch := range ref {
+		if len(got[ch]) != len(ref[ch]) {
+			t.Fatalf("channel %d: got %d samples, want %d", ch, len(got[ch]), len(ref[ch]))
+		}
+		maxDiff := 0.0
+		for i, v := range ref[ch] {
+			maxDiff = math.Max(maxDiff, math.Abs(got[ch][i]-v))
+		}
+		t.Logf("channel %d: max difference %g", ch, maxDiff)
+		// within 1 LSB of 16 bit PCM
+		if maxDiff > 1.0/(1<<15) {
+			t.Errorf("channel %d: fixed point differs from float64 by %g", ch, maxDiff)
+		}
+	}
+}
+
+func TestDecodeFixedPointInt16(t *testing.T) {
+	vd := openTestDecoder(t)
+	ref, err := vd.DecodeAllInt16()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vd.FixedPoint = true
+	got, err := vd.DecodeAllInt16()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ch := range ref {
+		if len(got[ch]) != len(ref[ch]) {
+			t.Fatalf("channel %d: got %d samples, want %d", ch, len(got[ch]), len(ref[ch]))
+		}
+		for i, v := range ref[ch] {
+			if d := int(got[ch][i]) - int(v); d < -1 || d > 1 {
+				t.Fatalf("channel %d, sample %d: got %d, want %d", ch, i, got[ch][i], v)
+			}
+		}
+	}
+}
+
+func TestFixedVectorSaturates(t *testing.T) {
+	// largest exponent and mantissa of both signs
+	const huge, negHuge = 0x7fffffff, 0xffffffff
+	for _, c := range []struct {
+		minimum, delta uint32
+		want           int32
+	}{
+		{huge, huge, math.MaxInt32},
+		{negHuge, negHuge, math.MinInt32},
+		{0, huge, math.MaxInt32},
+	} {
+		vq := vqLookup{
+			lookupType:    2,
+			dimension:     4,
+			entryLen:      1,
+			multiplicands: []uint32{math.MaxUint32, math.MaxUint32, math.MaxUint32, math.MaxUint32},
+			lookupValues:  4,
+			fixedMin:      clampFixed(toFixed(c.minimum, vqFracBits), vqFixedLimit),
+			fixedDelta:    clampFixed(toFixed(c.delta, vqFracBits), math.MaxInt32),
+			seqFlag:       true,
+		}
+		v := make([]int32, 4)
+		vq.fixedVector(0, v)
+		for j, val := range v {
+			if val != c.want {
+				t.Errorf("min %#x, delta %#x: element %d is %d, want %d", c.minimum, c.delta, j, val, c.want)
+			}
+		}
+	}
+}
@@ -2,8 +2,10 @@ package vorbis
 
 import (
 	"errors"
-	"github.com/sr8e/vorbis/ogg"
+	"fmt"
 	"slices"
+
+	"github.com/sr8e/vorbis/ogg"
 )
 
 type floorConfig struct {
@@ -16,6 +18,17 @@ type floor1Config struct {
 	partitions []uint8
 	classes    []floor1Class
 	multiplier uint8
+
+	// derived from xList on reading header
+	sortedIndex   []int
+	lowNeighbors  []int
+	highNeighbors []int
+}
+
+// floorBuffer holds working memory for floor decode.
+type floorBuffer struct {
+	yValues    []int
+	step2Flags []bool
 }
 
 type floor1Class struct {
@@ -27,7 +40,10 @@ type floor1Class struct {
 
 var floor1Multiplier = []int{256, 128, 86, 64}
 
-func readFloorConfig(p *ogg.Packet) ([]floorConfig, error) {
+// floor1MaxPoints is the maximum number of X values in floor 1 including both ends.
+const floor1MaxPoints = 65
+
+func readFloorConfig(p *ogg.Packet, cbLen int) ([]floorConfig, error) {
 	tmp, err := p.GetUint(6)
 	if err != nil {
 		return nil, err
@@ -42,20 +58,20 @@ func readFloorConfig(p *ogg.Packet) ([]floorConfig, error) {
 
 		if floorType == 0 {
 			// TODO
-			return nil, errors.New("floor type 0 is not implemented yet :(")
+			return nil, ErrUnsupportedFloor
 		} else if floorType == 1 {
-			configs[i], err = readFloor1Header(p)
+			configs[i], err = readFloor1Header(p, cbLen)
 			if err != nil {
 				return nil, err
 			}
 		} else {
-			return nil, errors.New("invalid floor Type")
+			return nil, fmt.Errorf("invalid floor type %d", floorType)
 		}
 	}
 	return configs, nil
 }
 
-func readFloor1Header(p *ogg.Packet) (_ floorConfig, err error) {
+func readFloor1Header(p *ogg.Packet, cbLen int) (_ floorConfig, err error) {
 	partLen, err := p.GetUint(5)
 	if err != nil {
 		return
@@ -68,7 +84,10 @@ func readFloor1Header(p *ogg.Packet) (_ floorConfig, err error) {
 		}
 	}
 
-	clsSize := slices.Max(partCls) + 1
+	var clsSize uint8
+	if len(partCls) > 0 {
+		clsSize = slices.Max(partCls) + 1
+	}
 	classes := make([]floor1Class, clsSize)
 	for i := range classes {
 		var dim, subcls, masterBook uint8
@@ -88,6 +107,10 @@ func readFloor1Header(p *ogg.Packet) (_ floorConfig, err error) {
 			if err != nil {
 				return
 			}
+			if int(masterBook) >= cbLen {
+				err = fmt.Errorf("floor refers to undefined codebook %d", masterBook)
+				return
+			}
 		}
 
 		subBookLen := 1 << subcls
@@ -98,6 +121,10 @@ func readFloor1Header(p *ogg.Packet) (_ floorConfig, err error) {
 			if err != nil {
 				return
 			}
+			if tmp > cbLen {
+				err = fmt.Errorf("floor refers to undefined codebook %d", tmp-1)
+				return
+			}
 			subBooks[j] = tmp - 1
 		}
 		classes[i] = floor1Class{
@@ -111,6 +138,7 @@ func readFloor1Header(p *ogg.Packet) (_ floorConfig, err error) {
 	if err != nil {
 		return
 	}
+	mul += 1
 	rangeBits, err := p.GetUint(4)
 	if err != nil {
 		return
@@ -128,42 +156,72 @@ func readFloor1Header(p *ogg.Packet) (_ floorConfig, err error) {
 			xList = append(xList, v)
 		}
 	}
+	if len(xList) > floor1MaxPoints {
+		err = fmt.Errorf("too many floor points: %d", len(xList))
+		return
+	}
+	sortedIndex := make([]int, len(xList))
+	for i := range sortedIndex {
+		sortedIndex[i] = i
+	}
+	slices.SortFunc(sortedIndex, func(a, b int) int { return int(xList[a]) - int(xList[b]) })
+	for i := 1; i < len(sortedIndex); i++ {
+		if xList[sortedIndex[i-1]] == xList[sortedIndex[i]] {
+			err = errors.New("duplicate floor X values")
+			return
+		}
+	}
+
+	lowNeighbors := make([]int, len(xList))
+	highNeighbors := make([]int, len(xList))
+	for i := 2; i < len(xList); i++ {
+		lowNeighbors[i] = lowNeighbor(xList, i)
+		highNeighbors[i] = highNeighbor(xList, i)
+	}
+
 	config := floor1Config{
-		xList:      xList,
-		partitions: partCls,
-		classes:    classes,
-		multiplier: mul,
+		xList:         xList,
+		partitions:    partCls,
+		classes:       classes,
+		multiplier:    mul,
+		sortedIndex:   sortedIndex,
+		lowNeighbors:  lowNeighbors,
+		highNeighbors: highNeighbors,
 	}
 	return floorConfig{
 		floorType: 1,
 		config1:   &config,
 	}, nil
 }
-func readFloorPacket(p *ogg.Packet, blockExp int, config floorConfig, codebooks []codebook) ([]int, error) {
+
+// readFloorPacket renders floor curve into finalY, and reports whether the floor is used.
+func readFloorPacket(p *ogg.Packet, config floorConfig, codebooks []codebook, buf *floorBuffer, finalY []int) (bool, error) {
 	if config.floorType == 0 {
-		return nil, errors.New("not implemented yet")
+		return false, ErrUnsupportedFloor
 	} else if config.floorType == 1 {
-		return readFloor1Packet(p, blockExp, *config.config1, codebooks)
+		return readFloor1Packet(p, *config.config1, codebooks, buf, finalY)
 	}
-	return nil, errors.New("invalid floor type")
+	return false, errors.New("invalid floor type")
 }
 
-func readFloor1Packet(p *ogg.Packet, blockExp int, config floor1Config, codebooks []codebook) ([]int, error) {
+func readFloor1Packet(p *ogg.Packet, config floor1Config, codebooks []codebook, buf *floorBuffer, finalY []int) (bool, error) {
 	nonZeroFlag, err := p.GetFlag()
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 	if !nonZeroFlag { // unused floor
-		return nil, nil
+		return false, nil
 	}
 	yRange := floor1Multiplier[config.multiplier-1]
 	yBits := fls(yRange - 1)
-	yValues := make([]int, 0)
-	yInits, err := p.GetUintSerial(yBits, yBits)
-	if err != nil {
-		return nil, err
+	yValues := buf.yValues[:0]
+	for i := 0; i < 2; i++ {
+		yInit, err := p.GetUint(yBits)
+		if err != nil {
+			return false, err
+		}
+		yValues = append(yValues, int(yInit))
 	}
-	yValues = append(yValues, int(yInits[0]), int(yInits[1]))
 
 	for _, clsIndex := range config.partitions {
 		cls := config.classes[clsIndex]
@@ -174,7 +232,7 @@ func readFloor1Packet(p *ogg.Packet, blockExp int, config floor1Config, codebook
 		if cbits > 0 {
 			cval, err = codebooks[cls.masterBook].ReadScalarValue(p)
 			if err != nil {
-				return nil, err
+				return false, err
 			}
 		}
 		for j := 0; j < int(cls.dimension); j++ {
@@ -183,7 +241,7 @@ func readFloor1Packet(p *ogg.Packet, blockExp int, config floor1Config, codebook
 			if book >= 0 {
 				yVal, err := codebooks[book].ReadScalarValue(p)
 				if err != nil {
-					return nil, err
+					return false, err
 				}
 				yValues = append(yValues, yVal)
 			} else {
@@ -191,18 +249,35 @@ func readFloor1Packet(p *ogg.Packet, blockExp int, config floor1Config, codebook
 			}
 		}
 	}
+	buf.yValues = yValues
 	xValues := config.xList
 	if len(xValues) != len(yValues) {
-		return nil, errors.New("floor curve value length mismatch")
+		return false, errors.New("floor curve value length mismatch")
 	}
 
+	step2Flags := grow(buf.step2Flags, len(yValues))
+	buf.step2Flags = step2Flags
+	step2Flags[0] = true
+	step2Flags[1] = true
+
 	for i := 2; i < len(yValues); i++ {
-		lowNeigh := lowNeighbor(xValues, i)
-		highNeigh := highNeighbor(xValues, i)
+		lowNeigh := config.lowNeighbors[i]
+		highNeigh := config.highNeighbors[i]
 		pred := renderPoint(xValues[lowNeigh], xValues[highNeigh], yValues[lowNeigh], yValues[highNeigh], xValues[i])
-		room := 2 * min(pred, yRange-pred)
+		highRoom := yRange - pred
+		lowRoom := pred
+		room := 2 * min(highRoom, lowRoom)
 
-		if val := yValues[i]; val < room {
+		val := yValues[i]
+		step2Flags[i] = val != 0
+		if val == 0 {
+			yValues[i] = pred
+			continue
+		}
+		step2Flags[lowNeigh] = true
+		step2Flags[highNeigh] = true
+
+		if val < room {
 			sign := val & 1
 			diff := val >> 1
 			if sign == 1 {
@@ -210,34 +285,31 @@ func readFloor1Packet(p *ogg.Packet, blockExp int, config floor1Config, codebook
 			}
 			yValues[i] = pred + diff
 		} else {
-			if yRange > 2*pred {
-				yValues[i] = val
+			if highRoom > lowRoom {
+				yValues[i] = val - lowRoom + pred
 			} else {
-				yValues[i] = yRange - val - 1
+				yValues[i] = pred - val + highRoom - 1
 			}
 		}
 	}
-	sortedIndex := make([]int, len(xValues))
-	for i := range sortedIndex {
-		sortedIndex[i] = i
-	}
-	slices.SortFunc(sortedIndex, func(a, b int) int { return int(xValues[a]) - int(xValues[b]) })
 
-	n := uint16(1 << blockExp)
-	finalY := make([]int, n)
+	// finalY holds the indices of inverse dB table, not the amplitude itself
+	n := len(finalY)
+	mul := int(config.multiplier)
 
-	prevIndex := sortedIndex[0]
-	for _, curIndex := range sortedIndex[1:] {
-		x0 := xValues[prevIndex]
-		x1 := xValues[curIndex]
-		for x := x0; x < min(x1, n); x++ {
-			finalY[x] = renderPoint(x0, x1, yValues[prevIndex], yValues[curIndex], x)
+	lx, ly := 0, yValues[config.sortedIndex[0]]*mul
+	hx, hy := 0, 0
+	for _, curIndex := range config.sortedIndex[1:] {
+		if !step2Flags[curIndex] {
+			continue
 		}
-		prevIndex = curIndex
+		hx, hy = int(xValues[curIndex]), yValues[curIndex]*mul
+		renderLine(lx, ly, hx, hy, finalY)
+		lx, ly = hx, hy
 	}
-	for x := xValues[1]; x < n; x++ {
-		finalY[x] = yValues[1]
+	if hx < n {
+		renderLine(hx, hy, n, hy, finalY)
 	}
 
-	return finalY, nil
+	return true, nil
 }
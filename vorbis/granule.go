@@ -0,0 +1,80 @@
+package vorbis
+
+import (
+	"errors"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+// NewGranuleFunc returns the function computing granule positions of a Vorbis stream from block sizes of packets,
+// to be used for repairing pages by ogg.Stream.Repair.
+// Positions are unknown if headers of the stream cannot be read, and for malformed audio packets.
+func NewGranuleFunc() ogg.GranuleFunc {
+	var (
+		index     int
+		ident     Identification
+		setup     VorbisSetup
+		ready     bool
+		prevBlock int
+		pos       uint64
+	)
+	return func(p *ogg.Packet) (uint64, bool) {
+		i := index
+		index++
+		switch i {
+		case 0:
+			var err error
+			ident, err = readIdentification(p)
+			return 0, err == nil
+		case 1:
+			return 0, ident.Channels != 0
+		case 2:
+			if ident.Channels == 0 {
+				return 0, false
+			}
+			var err error
+			setup, err = readSetup(p, ident, -1, Limits{})
+			ready = err == nil
+			return 0, ready
+		}
+		if !ready {
+			return 0, false
+		}
+
+		blockSize, err := packetBlockSize(p, ident, setup)
+		if err != nil {
+			return 0, false
+		}
+		if p.Discontinuous() { // decoding restarts at this packet
+			prevBlock = 0
+		}
+		// overlap of the previous block and this one is finished
+		if prevBlock > 0 {
+			pos += uint64(prevBlock/4 + blockSize/4)
+		}
+		prevBlock = blockSize
+		return pos, true
+	}
+}
+
+// packetBlockSize returns the block size of an audio packet.
+func packetBlockSize(p *ogg.Packet, ident Identification, vs VorbisSetup) (int, error) {
+	packetType, err := p.GetFlag()
+	if err != nil {
+		return 0, err
+	}
+	if packetType {
+		return 0, errors.New("invalid packet type flag")
+	}
+	modeNum, err := p.GetUint(fls(len(vs.modeConfigs) - 1))
+	if err != nil {
+		return 0, err
+	}
+	if int(modeNum) >= len(vs.modeConfigs) {
+		return 0, errors.New("invalid mode number")
+	}
+	if vs.modeConfigs[modeNum].blockFlag {
+		return 1 << ident.BlockExp[1], nil
+	}
+	return 1 << ident.BlockExp[0], nil
+}
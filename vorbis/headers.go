@@ -13,19 +13,24 @@ func readCommonHeader(p *ogg.Packet, headerOrder uint8) error {
 		return err
 	}
 	if packetType&1 != 1 || packetType>>1 != headerOrder {
-		return fmt.Errorf("invalid header type %x at packet %d", packetType, headerOrder)
+		return fmt.Errorf("%w: invalid header type %x at packet %d", ErrNotVorbis, packetType, headerOrder)
 	}
 	pattern, err := p.GetBytes(6)
 	if err != nil {
 		return err
 	}
 	if string(pattern) != "vorbis" {
-		return errors.New("invalid header packet")
+		return fmt.Errorf("%w: invalid header packet", ErrNotVorbis)
 	}
 	return nil
 }
 
 func readIdentification(p *ogg.Packet) (_ Identification, err error) {
+	defer func() {
+		if err != nil {
+			err = newHeaderError(p, 0, "identification", err)
+		}
+	}()
 	err = readCommonHeader(p, 0)
 	if err != nil {
 		return
@@ -40,6 +45,10 @@ func readIdentification(p *ogg.Packet) (_ Identification, err error) {
 		err = errors.New("incompatible vorbis version")
 		return
 	}
+	if fields[1] == 0 || fields[2] == 0 {
+		err = errors.New("channels and sample rate must be non-zero")
+		return
+	}
 	var bitRate [3]int32
 	for i, v := range fields[3:6] {
 		bitRate[i] = int32(v)
@@ -52,6 +61,10 @@ func readIdentification(p *ogg.Packet) (_ Identification, err error) {
 		}
 		blockExp[i] = byte(v)
 	}
+	if blockExp[0] > blockExp[1] {
+		err = errors.New("short block is larger than long block")
+		return
+	}
 	if fields[8] != 1 {
 		err = errors.New("framing bit not set")
 		return
@@ -65,24 +78,89 @@ func readIdentification(p *ogg.Packet) (_ Identification, err error) {
 	}, nil
 }
 
-func readSetup(p *ogg.Packet, ident Identification) (_ VorbisSetup, err error) {
+func readComment(p *ogg.Packet) (_ Comment, err error) {
+	defer func() {
+		if err != nil {
+			err = newHeaderError(p, 1, "comment", err)
+		}
+	}()
+	err = readCommonHeader(p, 1)
+	if err != nil {
+		return
+	}
+
+	readString := func() (string, error) {
+		l, err := p.GetUint(32)
+		if err != nil {
+			return "", err
+		}
+		b, err := p.GetBytes(l)
+		return string(b), err
+	}
+	vendor, err := readString()
+	if err != nil {
+		return
+	}
+	n, err := p.GetUint(32)
+	if err != nil {
+		return
+	}
+	// each comment takes 4 bytes at least
+	if int(n) > p.BitsLeft()/32 {
+		err = fmt.Errorf("too many comments: %d", n)
+		return
+	}
+	comments := make([]string, n)
+	for i := range comments {
+		comments[i], err = readString()
+		if err != nil {
+			return
+		}
+	}
+	framingBit, err := p.GetFlag()
+	if err != nil {
+		return
+	}
+	if !framingBit {
+		err = errors.New("framing bit not set")
+		return
+	}
+	return Comment{Vendor: vendor, Comments: comments}, nil
+}
+
+// readSetup reads setup header. VQ lookup tables are expanded in advance as long as they fit in vqBudget bytes.
+// Every reference between configurations is validated, so that decoding audio packets never goes out of range.
+func readSetup(p *ogg.Packet, ident Identification, vqBudget int, limits Limits) (_ VorbisSetup, err error) {
+	section := "common header"
+	defer func() {
+		if err != nil {
+			err = newHeaderError(p, 2, section, err)
+		}
+	}()
 	err = readCommonHeader(p, 2)
 	if err != nil {
 		return
 	}
+	section = "codebooks"
 	cbLen, err := p.GetUint(8)
 	if err != nil {
 		return
 	}
+	budget := limits.budget()
 	codebooks := make([]codebook, cbLen+1)
 	for i := range codebooks {
-		codebooks[i], err = readCodebook(p)
+		section = fmt.Sprintf("codebook %d", i)
+		codebooks[i], err = readCodebook(p, &budget)
 		if err != nil {
 			return
 		}
 	}
+	for i := range codebooks {
+		vqBudget = codebooks[i].vqMap.expand(vqBudget)
+	}
 
 	// placeholder, discard
+	section = "time domain transforms"
 	tdt, err := p.GetUint(6)
 	if err != nil {
 		return
@@ -99,26 +177,31 @@ func readSetup(p *ogg.Packet, ident Identification) (_ VorbisSetup, err error) {
 		}
 	}
 
-	floorConfigs, err := readFloorConfig(p)
+	section = "floors"
+	floorConfigs, err := readFloorConfig(p, len(codebooks))
 	if err != nil {
 		return
 	}
 
-	residueConfigs, err := readResidueConfig(p)
+	section = "residues"
+	residueConfigs, err := readResidueConfig(p, codebooks)
 	if err != nil {
 		return
 	}
 
-	mappingConfigs, err := readMappingConfigs(p, ident)
+	section = "mappings"
+	mappingConfigs, err := readMappingConfigs(p, ident, len(floorConfigs), len(residueConfigs))
 	if err != nil {
 		return
 	}
 
-	modeConfigs, err := readModeConfigs(p)
+	section = "modes"
+	modeConfigs, err := readModeConfigs(p, len(mappingConfigs))
 	if err != nil {
 		return
 	}
 
+	section = "framing"
 	framingBit, err := p.GetFlag()
 	if err != nil {
 		return
@@ -137,7 +220,7 @@ func readSetup(p *ogg.Packet, ident Identification) (_ VorbisSetup, err error) {
 	}, nil
 }
 
-func readModeConfigs(p *ogg.Packet) ([]modeConfig, error) {
+func readModeConfigs(p *ogg.Packet, mapLen int) ([]modeConfig, error) {
 	modeLen, err := p.GetUint(6)
 	if err != nil {
 		return nil, err
@@ -153,6 +236,9 @@ func readModeConfigs(p *ogg.Packet) ([]modeConfig, error) {
 		if fields[1] != 0 || fields[2] != 0 {
 			return nil, errors.New("invalid non-zero value in mode config")
 		}
+		if int(fields[3]) >= mapLen {
+			return nil, fmt.Errorf("mode refers to undefined mapping %d", fields[3])
+		}
 		modes[i] = modeConfig{
 			blockFlag: fields[0] == 1,
 			mapping:   uint8(fields[3]),
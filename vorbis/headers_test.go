@@ -0,0 +1,52 @@
+package vorbis
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+// malformed comment header does not stop decoding, and its error is kept.
+func TestMalformedComment(t *testing.T) {
+	vd := openTestDecoder(t)
+	want, err := vd.DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vd.CommentErr != nil {
+		t.Fatal(vd.CommentErr)
+	}
+
+	headers := make([][]byte, 3)
+	for i := range headers {
+		headers[i] = vd.Packets[i].Bytes()
+	}
+	// the comment header lacks the framing bit
+	comment := bytes.Clone(headers[1])
+	comment[len(comment)-1] = 0
+	for _, broken := range [][]byte{comment, headers[1][:12]} {
+		packets := append([]ogg.Packet{ogg.NewPacket(headers[0]), ogg.NewPacket(broken), ogg.NewPacket(headers[2])}, vd.Packets[3:]...)
+		bd := VorbisDecoder{Packets: packets}
+		got, err := bd.DecodeAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var he *HeaderError
+		if !errors.As(bd.CommentErr, &he) {
+			t.Errorf("got comment error %v, want *HeaderError", bd.CommentErr)
+		}
+		if len(got[0]) != len(want[0]) {
+			t.Errorf("got %d samples, want %d", len(got[0]), len(want[0]))
+		}
+
+		d, err := NewDecoder(headers[0], broken, headers[2], HeaderOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.CommentErr == nil {
+			t.Error("Decoder: comment error is lost")
+		}
+	}
+}
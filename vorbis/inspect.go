@@ -0,0 +1,60 @@
+package vorbis
+
+// PacketInfo describes how an audio packet is encoded.
+type PacketInfo struct {
+	Index      int // index of the packet in stream
+	Mode       int
+	BlockSize  int
+	PrevWindow bool // window flags of long block, set if the adjacent block is long
+	NextWindow bool
+	Unused     []bool // floor of each channel is unused, so that the channel is silent in this block
+	Bits       int    // bits consumed by decoding the packet
+	Size       int    // bits of the whole packet
+	Err        error  // error on decoding the packet
+}
+
+// InspectPackets decodes floors and residues of every audio packet without synthesis,
+// and passes the description of each packet to yield. It stops when yield returns error.
+// Malformed packets are reported through PacketInfo.Err instead of stopping.
+func (vd *VorbisDecoder) InspectPackets(yield func(PacketInfo) error) error {
+	if !vd.isReady {
+		err := vd.ReadHeaders()
+		if err != nil {
+			return err
+		}
+	}
+	bd := newBlockDecoder[float32](vd.Identification, vd.setup)
+	for _, packet := range vd.Packets[3:] {
+		p := packet
+		info := PacketInfo{Index: p.Index(), Size: p.BitsLeft()}
+
+		// peek mode number, which readBlock does not return
+		head := p
+		if _, err := head.GetFlag(); err == nil {
+			modeNum, err := head.GetUint(fls(len(vd.setup.modeConfigs) - 1))
+			if err == nil {
+				info.Mode = int(modeNum)
+			}
+		}
+
+		b, err := bd.readBlock(&p)
+		info.Bits = min(p.BitOffset(), info.Size)
+		if err != nil {
+			info.Err = newAudioPacketError(&p, err)
+		} else {
+			info.BlockSize = 1 << b.blockExp
+			if b.blockExp == int(vd.Identification.BlockExp[1]) {
+				info.PrevWindow = b.leftExp == b.blockExp
+				info.NextWindow = b.rightExp == b.blockExp
+			}
+			info.Unused = make([]bool, len(b.floors))
+			for ch, floor := range b.floors {
+				info.Unused[ch] = floor == nil
+			}
+		}
+		if err := yield(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package vorbis
 
 import (
 	"errors"
+	"fmt"
+
 	"github.com/sr8e/vorbis/ogg"
 )
 
@@ -16,7 +18,7 @@ type mappingSubmap struct {
 	residue uint32
 }
 
-func readMappingConfigs(p *ogg.Packet, ident Identification) ([]mappingConfig, error) {
+func readMappingConfigs(p *ogg.Packet, ident Identification, floorLen, residueLen int) ([]mappingConfig, error) {
 	mapLen, err := p.GetUint(6)
 	if err != nil {
 		return nil, err
@@ -42,6 +44,7 @@ func readMappingConfigs(p *ogg.Packet, ident Identification) ([]mappingConfig, e
 			if err != nil {
 				return nil, err
 			}
+			submapLen += 1
 		}
 		couplingFlag, err := p.GetFlag()
 		if err != nil {
@@ -62,6 +65,10 @@ func readMappingConfigs(p *ogg.Packet, ident Identification) ([]mappingConfig, e
 				if err != nil {
 					return nil, err
 				}
+				mag, ang := polarMap[j][0], polarMap[j][1]
+				if mag == ang || int(mag) >= int(ident.Channels) || int(ang) >= int(ident.Channels) {
+					return nil, fmt.Errorf("invalid coupling of channel %d and %d", mag, ang)
+				}
 			}
 		}
 		rsv, err := p.GetUint(2)
@@ -79,7 +86,7 @@ func readMappingConfigs(p *ogg.Packet, ident Identification) ([]mappingConfig, e
 				if err != nil {
 					return nil, err
 				}
-				if mapMux[j] > submapLen {
+				if mapMux[j] >= submapLen {
 					return nil, errors.New("invalid submap mux value")
 				}
 			}
@@ -90,6 +97,9 @@ func readMappingConfigs(p *ogg.Packet, ident Identification) ([]mappingConfig, e
 			if err != nil {
 				return nil, err
 			}
+			if int(fields[1]) >= floorLen || int(fields[2]) >= residueLen {
+				return nil, fmt.Errorf("submap refers to undefined floor %d or residue %d", fields[1], fields[2])
+			}
 			submaps[j] = mappingSubmap{
 				floor:   fields[1],
 				residue: fields[2],
@@ -2,11 +2,13 @@ package vorbis
 
 import (
 	"math"
+
+	"github.com/sr8e/vorbis/transform"
 )
 
 func toFloat(v uint32) float64 {
-	frac := v & 0x1fffff       // 21 bits
-	exp := (v>>21)&0x3ff - 788 // 10 bits
+	frac := v & 0x1fffff            // 21 bits
+	exp := int((v>>21)&0x3ff) - 788 // 10 bits
 
 	abs := float64(frac) * math.Pow(2, float64(exp))
 	if (v>>31)&1 == 0 {
@@ -15,9 +17,65 @@ func toFloat(v uint32) float64 {
 	return -abs
 }
 
+// fromFloat encodes f in the 32 bit float format of Vorbis, with normalized mantissa as libvorbis does.
+// It is the inverse of toFloat except for precision, and returns false if f is out of range.
+func fromFloat(f float64) (uint32, bool) {
+	if f == 0 {
+		return 0, true
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	var sign uint32
+	if f < 0 {
+		sign = 1 << 31
+		f = -f
+	}
+	frac, exp := math.Frexp(f)
+	mant := uint32(math.RoundToEven(math.Ldexp(frac, 21)))
+	exp -= 21
+	if mant == 1<<21 {
+		mant >>= 1
+		exp++
+	}
+	exp += 788
+	if exp < 0 || 0x3ff < exp {
+		return 0, false
+	}
+	return sign | uint32(exp)<<21 | mant, true
+}
+
+// lookup1Values returns the greatest integer r such that r^dimension <= entryLen.
 func lookup1Values(dimension uint16, entryLen uint32) int {
-	// TODO verify floating point error
-	return int(math.Floor(math.Pow(float64(entryLen), 1/float64(dimension))))
+	r := int(math.Floor(math.Pow(float64(entryLen), 1/float64(dimension))))
+	// correct floating point error of pow in both directions
+	for intPow(r+1, dimension) <= int(entryLen) {
+		r++
+	}
+	for r > 0 && intPow(r, dimension) > int(entryLen) {
+		r--
+	}
+	return r
+}
+
+// intPow returns base^exp, saturating at math.MaxInt32 to avoid overflow.
+func intPow(base int, exp uint16) int {
+	v := 1
+	for i := uint16(0); i < exp; i++ {
+		v *= base
+		if v > math.MaxInt32 {
+			return math.MaxInt32
+		}
+	}
+	return v
+}
+
+var inverseDBTable [0x100]float64
+
+func init() {
+	for i := range inverseDBTable {
+		inverseDBTable[i] = inverseDecibels(i)
+	}
 }
 
 func inverseDecibels(index int) float64 {
@@ -28,6 +86,14 @@ func inverseDecibels(index int) float64 {
 	return math.Pow(10, deciBel)
 }
 
+// floorAmplitude looks up the inverse dB table by the index rendered in floor curve.
+func floorAmplitude[F transform.Float](index int) F {
+	if index < 0 || index > 0xff {
+		return 0
+	}
+	return F(inverseDBTable[index])
+}
+
 // fls finds last set bit of integer.
 func fls(x int) (i uint32) {
 	if x <= 0 {
@@ -68,3 +134,50 @@ func highNeighbor(v []uint16, x int) int {
 func renderPoint(x0, x1 uint16, y0, y1 int, x uint16) int {
 	return y0 + (y1-y0)*int(x-x0)/int(x1-x0)
 }
+
+// renderLine draws integer line from (x0, y0) to (x1, y1) into v, excluding x1.
+func renderLine(x0, y0, x1, y1 int, v []int) {
+	dy := y1 - y0
+	adx := x1 - x0
+	if adx <= 0 {
+		return
+	}
+	ady := dy
+	if ady < 0 {
+		ady = -ady
+	}
+	base := dy / adx
+	sy := base + 1
+	if dy < 0 {
+		sy = base - 1
+	}
+	absBase := base
+	if absBase < 0 {
+		absBase = -absBase
+	}
+	ady -= absBase * adx
+
+	y := y0
+	errAcc := 0
+	if x0 < len(v) {
+		v[x0] = y
+	}
+	for x := x0 + 1; x < min(x1, len(v)); x++ {
+		errAcc += ady
+		if errAcc >= adx {
+			errAcc -= adx
+			y += sy
+		} else {
+			y += base
+		}
+		v[x] = y
+	}
+}
+
+// grow returns s resliced to length n, allocating only if its capacity is insufficient.
+func grow[T any](s []T, n int) []T {
+	if cap(s) < n {
+		return make([]T, n)
+	}
+	return s[:n]
+}
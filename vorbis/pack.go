@@ -0,0 +1,41 @@
+package vorbis
+
+import (
+	"fmt"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+// PackHeaders packs identification, comment and setup header packets in Xiph lacing,
+// the form of CodecPrivate of Matroska and WebM.
+func PackHeaders(headers []ogg.Packet) ([]byte, error) {
+	if len(headers) != 3 {
+		return nil, fmt.Errorf("%w: 3 header packets are required, got %d", ErrNotVorbis, len(headers))
+	}
+	packets := make([][]byte, len(headers))
+	for i := range headers {
+		packets[i] = headers[i].Bytes()
+	}
+	return ogg.XiphLace(packets)
+}
+
+// UnpackHeaders unpacks header packets packed by PackHeaders,
+// which are accepted by ReadHeaders as the first 3 of VorbisDecoder.Packets.
+func UnpackHeaders(data []byte) ([]ogg.Packet, error) {
+	packets, err := ogg.XiphUnlace(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(packets) != 3 {
+		return nil, fmt.Errorf("%w: 3 header packets are required, got %d", ErrNotVorbis, len(packets))
+	}
+	headers := make([]ogg.Packet, len(packets))
+	for i, b := range packets {
+		headers[i] = ogg.NewPacket(b)
+		head := headers[i]
+		if err := readCommonHeader(&head, uint8(i)); err != nil {
+			return nil, err
+		}
+	}
+	return headers, nil
+}
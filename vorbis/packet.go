@@ -0,0 +1,192 @@
+package vorbis
+
+import (
+	"context"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+// Decoder decodes Vorbis audio packets, regardless of the container carrying them.
+// VorbisDecoder is built on it for streams in Ogg.
+type Decoder struct {
+	Identification Identification
+	Comment        Comment
+	// CommentErr is the error on reading comment header, which leaves Comment empty.
+	// It does not stop decoding, since the comment is not needed for it.
+	CommentErr error
+	setup      VorbisSetup
+
+	// FixedPoint selects integer-only synthesis, whose output is bit-identical on every architecture.
+	FixedPoint bool
+	// Parallelism is the number of goroutines decoding packets concurrently.
+	// Packets are decoded sequentially if it is less than 2.
+	Parallelism int
+	// Concealment selects how malformed or lost audio packets are substituted.
+	// Substituted blocks are windowed to fit the blocks around them.
+	// Decoding fails on malformed packet if ConcealNone.
+	Concealment Concealment
+	// OnConceal is called with the range of output samples affected by substituted blocks.
+	OnConceal func(start, length int)
+
+	// state of DecodePacket, created on the first call
+	floatState *packetState[float32]
+	fixedState *packetState[int32]
+	lost       bool
+}
+
+// HeaderOptions bounds memory allocated on reading setup header, which may come from untrusted input.
+// The zero value selects the defaults.
+type HeaderOptions struct {
+	// VQTableBudget limits the memory in bytes for VQ lookup tables expanded on reading setup.
+	// Vectors of codebooks beyond the budget are computed on demand.
+	// DefaultVQTableBudget is used if zero, and no table is expanded if negative.
+	VQTableBudget int
+	// Limits bounds memory allocated on reading setup header.
+	Limits Limits
+}
+
+// NewDecoder returns the decoder of the stream whose identification, comment and setup
+// header packets are given, read with opts. Headers in Xiph lacing are unpacked by UnpackHeaders.
+func NewDecoder(ident, comment, setup []byte, opts HeaderOptions) (*Decoder, error) {
+	var d Decoder
+	headers := []ogg.Packet{ogg.NewPacket(ident), ogg.NewPacket(comment), ogg.NewPacket(setup)}
+	if err := d.readHeaders(headers, opts); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// readHeaders reads identification, comment and setup header from the 3 packets.
+// Error on reading comment header is kept in CommentErr, which is not fatal.
+func (d *Decoder) readHeaders(headers []ogg.Packet, opts HeaderOptions) error {
+	ident, err := readIdentification(&headers[0])
+	if err != nil {
+		return err
+	}
+	comment, commentErr := readComment(&headers[1])
+	vqBudget := opts.VQTableBudget
+	if vqBudget == 0 {
+		vqBudget = DefaultVQTableBudget
+	}
+	vs, err := readSetup(&headers[2], ident, vqBudget, opts.Limits)
+	if err != nil {
+		return err
+	}
+	d.Identification = ident
+	d.Comment = comment
+	d.CommentErr = commentErr
+	d.setup = vs
+	return nil
+}
+
+// Setup returns the setup header.
+func (d *Decoder) Setup() VorbisSetup {
+	return d.setup
+}
+
+// DecodePacket decodes an audio packet, and returns the samples finished by it for each channel,
+// which are valid until the next call.
+// The first packet returns no samples since it only primes overlap with the next one,
+// and so does the packet after Reset or an error.
+// Samples of blocks substituted by concealment are returned together with those of the next packet.
+// The end of stream is not trimmed, which is left to the container knowing the length.
+// Options are read on the first call.
+func (d *Decoder) DecodePacket(data []byte) ([][]float32, error) {
+	lost := d.lost
+	d.lost = false
+	if d.FixedPoint {
+		if d.fixedState == nil {
+			d.fixedState = newPacketState(d, newFixedDecoder, fixedToFloat[float32])
+		}
+		return d.fixedState.decode(data, lost)
+	}
+	if d.floatState == nil {
+		d.floatState = newPacketState(d, newFloatDecoder[float32], func(v float32) float32 { return v })
+	}
+	return d.floatState.decode(data, lost)
+}
+
+// Lost tells that packets are lost before the next one passed to DecodePacket.
+// They are concealed as Concealment selects, or forgotten like Reset if ConcealNone.
+func (d *Decoder) Lost() {
+	d.lost = true
+}
+
+// Reset forgets the previous packet, on seeking.
+func (d *Decoder) Reset() {
+	d.lost = false
+	if d.floatState != nil {
+		d.floatState.sq.reset()
+	}
+	if d.fixedState != nil {
+		d.fixedState.sq.reset()
+	}
+}
+
+// Decode decodes audio packets returned by next until io.EOF, and passes finished samples to yield,
+// which are valid only during the call. ctx is passed to next.
+// Packets are decoded concurrently as Parallelism selects, independently of DecodePacket.
+func (d *Decoder) Decode(ctx context.Context, next func(context.Context) ([]byte, error), yield func([][]float32) error) error {
+	nextPacket := func(ctx context.Context) (ogg.Packet, error) {
+		data, err := next(ctx)
+		return ogg.NewPacket(data), err
+	}
+	if !d.FixedPoint {
+		return decodeStream(ctx, d, nextPacket, newFloatDecoder[float32], yield)
+	}
+	converted := make([][]float32, d.Identification.Channels)
+	return decodeStream(ctx, d, nextPacket, newFixedDecoder, func(chunk [][]int32) error {
+		for ch, v := range chunk {
+			converted[ch] = grow(converted[ch], len(v))
+			for i, val := range v {
+				converted[ch][i] = fixedToFloat[float32](val)
+			}
+		}
+		return yield(converted)
+	})
+}
+
+// packetState decodes packets one by one for DecodePacket.
+type packetState[S sample] struct {
+	decoder packetDecoder[S]
+	packet  ogg.Packet // kept here, so that it does not escape to heap for each call
+	sq      *sequencer[S]
+	conv    func(S) float32
+	samples [][]float32
+	emit    func([][]S) error
+}
+
+func newPacketState[S sample, D packetDecoder[S]](d *Decoder, newDecoder func(Identification, VorbisSetup) D, conv func(S) float32) *packetState[S] {
+	newPacketDecoder := func() packetDecoder[S] {
+		return newDecoder(d.Identification, d.setup)
+	}
+	st := &packetState[S]{
+		decoder: newPacketDecoder(),
+		sq:      newSequencer[S](d.Identification, d.Concealment, newPacketDecoder),
+		conv:    conv,
+		samples: make([][]float32, d.Identification.Channels),
+	}
+	st.sq.onConceal = d.OnConceal
+	st.emit = func(chunk [][]S) error {
+		for ch, v := range chunk {
+			for _, val := range v {
+				st.samples[ch] = append(st.samples[ch], st.conv(val))
+			}
+		}
+		return nil
+	}
+	return st
+}
+
+func (st *packetState[S]) decode(data []byte, lost bool) ([][]float32, error) {
+	for ch := range st.samples {
+		st.samples[ch] = st.samples[ch][:0]
+	}
+	st.packet = ogg.NewPacket(data)
+	b, err := st.decoder.decode(&st.packet)
+	if err := st.sq.push(decodedPacket[S]{block: b, discontinuous: lost, err: err}, st.emit); err != nil {
+		st.sq.reset()
+		return nil, err
+	}
+	return st.samples, nil
+}
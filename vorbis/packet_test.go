@@ -0,0 +1,162 @@
+package vorbis
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+// Decoder decodes packets one by one, or as a stream, into the same samples as VorbisDecoder with the same options.
+func TestDecoderOptions(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		fixedPoint  bool
+		concealment Concealment
+	}{
+		{"float", false, ConcealNone},
+		{"fixed point", true, ConcealNone},
+		{"repeat", false, ConcealRepeat},
+		{"fixed point silence", true, ConcealSilence},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			vd := openTestDecoder(t)
+			if c.concealment != ConcealNone {
+				corrupted := bytes.Clone(vd.Packets[10].Bytes())
+				corrupted[0] |= 1 // not an audio packet
+				vd.Packets[10] = ogg.NewPacket(corrupted)
+			}
+			vd.FixedPoint, vd.Concealment = c.fixedPoint, c.concealment
+			want, err := vd.DecodeAllFloat32()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			newDecoder := func() *Decoder {
+				d, err := NewDecoder(vd.Packets[0].Bytes(), vd.Packets[1].Bytes(), vd.Packets[2].Bytes(), HeaderOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				d.FixedPoint, d.Concealment = c.fixedPoint, c.concealment
+				return d
+			}
+			// Decoder lacks granule positions, so that the end is not trimmed
+			check := func(method string, got [][]float32) {
+				t.Helper()
+				for ch := range want {
+					if len(got[ch]) < len(want[ch]) {
+						t.Fatalf("%s: channel %d: got %d samples, want at least %d", method, ch, len(got[ch]), len(want[ch]))
+					}
+					for i, v := range want[ch] {
+						if got[ch][i] != v {
+							t.Fatalf("%s: channel %d: sample %d is %g, want %g", method, ch, i, got[ch][i], v)
+						}
+					}
+				}
+			}
+
+			d := newDecoder()
+			got := make([][]float32, len(want))
+			for _, p := range vd.Packets[3:] {
+				chunk, err := d.DecodePacket(p.Bytes())
+				if err != nil {
+					t.Fatal(err)
+				}
+				for ch, v := range chunk {
+					got[ch] = append(got[ch], v...)
+				}
+			}
+			check("DecodePacket", got)
+
+			d = newDecoder()
+			d.Parallelism = 4
+			packets := vd.Packets[3:]
+			got = make([][]float32, len(want))
+			err = d.Decode(context.Background(), func(context.Context) ([]byte, error) {
+				if len(packets) == 0 {
+					return nil, io.EOF
+				}
+				p := packets[0]
+				packets = packets[1:]
+				return p.Bytes(), nil
+			}, func(chunk [][]float32) error {
+				for ch, v := range chunk {
+					got[ch] = append(got[ch], v...)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			check("Decode", got)
+		})
+	}
+}
+
+func TestDecoderLost(t *testing.T) {
+	vd := openTestDecoder(t)
+	d, err := NewDecoder(vd.Packets[0].Bytes(), vd.Packets[1].Bytes(), vd.Packets[2].Bytes(), HeaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Concealment = ConcealRepeat
+	var concealed [][2]int
+	d.OnConceal = func(start, length int) {
+		concealed = append(concealed, [2]int{start, length})
+	}
+	total := 0
+	for i, p := range vd.Packets[3:] {
+		if i == 10 {
+			d.Lost()
+		}
+		chunk, err := d.DecodePacket(p.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += len(chunk[0])
+	}
+	if len(concealed) != 1 {
+		t.Fatalf("concealed %v, want a range", concealed)
+	}
+	if start, length := concealed[0][0], concealed[0][1]; start <= 0 || start+length > total {
+		t.Errorf("concealed %d samples from %d, out of %d samples", length, start, total)
+	}
+}
+
+func TestNewDecoderOptions(t *testing.T) {
+	vd := openTestDecoder(t)
+	headers := [3][]byte{vd.Packets[0].Bytes(), vd.Packets[1].Bytes(), vd.Packets[2].Bytes()}
+	opts := HeaderOptions{Limits: Limits{MaxCodebookEntries: 1}}
+	if _, err := NewDecoder(headers[0], headers[1], headers[2], opts); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("got %v with a limit of 1 codebook entry, want ErrLimitExceeded", err)
+	}
+
+	// vectors computed on demand decode the same samples
+	want, err := NewDecoder(headers[0], headers[1], headers[2], HeaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewDecoder(headers[0], headers[1], headers[2], HeaderOptions{VQTableBudget: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range vd.Packets[3:] {
+		w, err := want.DecodePacket(p.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := got.DecodePacket(p.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for ch := range w {
+			if !slices.Equal(g[ch], w[ch]) {
+				t.Fatalf("packet %d: channel %d differs without VQ tables", i, ch)
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package vorbis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+// decodedPacket is the result of decoding a packet.
+type decodedPacket[S sample] struct {
+	block         block[S]
+	discontinuous bool   // packets before this one are lost
+	granule       uint64 // granule position of the page where the packet ends, if hasGranule
+	hasGranule    bool
+	last          bool // the packet ends the stream
+	err           error
+}
+
+func newDecodedPacket[S sample](p *ogg.Packet, b block[S], err error) decodedPacket[S] {
+	granule, ok := p.Granule()
+	return decodedPacket[S]{
+		block:         b,
+		discontinuous: p.Discontinuous(),
+		granule:       granule,
+		hasGranule:    ok,
+		last:          p.EndOfStream(),
+		err:           err,
+	}
+}
+
+type blockResult[S sample] struct {
+	decodedPacket[S]
+	decoder packetDecoder[S]
+}
+
+type blockJob[S sample] struct {
+	packet ogg.Packet
+	slot   chan<- blockResult[S]
+}
+
+// decodeBlocks decodes each packet returned by next until io.EOF, and passes the result to yield in packet order.
+// With parallelism more than 1, packets are decoded concurrently by that number of workers,
+// while yield is always called sequentially, and next is called from another goroutine.
+// The context given to next is cancelled on return, and every goroutine has exited by then.
+// ctx.Err() is returned when ctx is done before io.EOF.
+// Decoders created by newDecoder are reused, and blocks passed to yield are valid only during the call.
+// Errors of decoding packets are passed to yield too, which returns error to abort.
+func decodeBlocks[S sample](ctx context.Context, next func(context.Context) (ogg.Packet, error), parallelism int, newDecoder func() packetDecoder[S], yield func(decodedPacket[S]) error) error {
+	if parallelism <= 1 {
+		decoder := newDecoder()
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			packet, err := next(ctx)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			b, err := decoder.decode(&packet)
+			if err := yield(newDecodedPacket(&packet, b, err)); err != nil {
+				return err
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan blockJob[S])
+	// pending results in packet order. capacity bounds the number of blocks in flight.
+	slots := make(chan chan blockResult[S], parallelism)
+
+	// decoders are returned to the pool after their blocks are yielded.
+	// at most parallelism+1 results wait to be yielded while every worker holds a decoder,
+	// so that a worker never starves.
+	pool := make(chan packetDecoder[S], 2*parallelism+1)
+	for i := 0; i < cap(pool); i++ {
+		pool <- newDecoder()
+	}
+
+	wg.Add(parallelism + 1)
+	for i := 0; i < parallelism; i++ {
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				var decoder packetDecoder[S]
+				select {
+				case decoder = <-pool:
+				case <-ctx.Done():
+					return
+				}
+				b, err := decoder.decode(&job.packet)
+				job.slot <- blockResult[S]{newDecodedPacket(&job.packet, b, err), decoder}
+			}
+		}()
+	}
+
+	// error of next other than io.EOF, read after slots are closed
+	var nextErr error
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		defer close(slots)
+		for {
+			packet, err := next(ctx)
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					nextErr = err
+				}
+				return
+			}
+			slot := make(chan blockResult[S], 1)
+			select {
+			case slots <- slot:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- blockJob[S]{packet: packet, slot: slot}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for slot := range slots {
+		// a slot is left unfilled when the context is cancelled
+		var res blockResult[S]
+		select {
+		case res = <-slot:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := yield(res.decodedPacket); err != nil {
+			return err
+		}
+		pool <- res.decoder
+	}
+	if nextErr != nil {
+		return nextErr
+	}
+	// the feeder stops without error when the context is cancelled
+	return ctx.Err()
+}
@@ -0,0 +1,67 @@
+package vorbis
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+// goroutines decoding concurrently have exited when DecodeFollow returns.
+func TestDecodeFollowStopsGoroutines(t *testing.T) {
+	data, err := os.ReadFile("../testdata/test.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errStop := errors.New("stop")
+	for _, c := range []struct {
+		name   string
+		follow bool
+		stopAt int  // chunk to abort at, or -1 to decode all
+		cancel bool // abort by cancelling the context instead of returning error
+		want   error
+	}{
+		{"eof", false, -1, false, nil},
+		{"abort", false, 1, false, errStop},
+		{"abort while following", true, 1, false, errStop},
+		{"cancel", false, 1, true, context.Canceled},
+		{"cancel while following", true, 1, true, context.Canceled},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			var ol ogg.OggLoader
+			if err := ol.OpenReader(bytes.NewReader(data)); err != nil {
+				t.Fatal(err)
+			}
+			ol.Follow = c.follow
+			ol.PollInterval = time.Millisecond
+			var vd VorbisDecoder
+			vd.Parallelism = 4
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			chunks := 0
+			err := vd.DecodeFollow(ctx, &ol, func([][]float64) error {
+				chunks++
+				if chunks == c.stopAt {
+					if c.cancel {
+						cancel()
+						return nil
+					}
+					return errStop
+				}
+				return nil
+			})
+			if !errors.Is(err, c.want) {
+				t.Fatalf("got %v, want %v", err, c.want)
+			}
+			if after := runtime.NumGoroutine(); after > before {
+				t.Errorf("%d goroutines left running", after-before)
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package vorbis
 
 import (
+	"errors"
 	"fmt"
+
 	"github.com/sr8e/vorbis/ogg"
 )
 
@@ -15,7 +17,7 @@ type residueConfig struct {
 	residueBooks  [][8]int
 }
 
-func readResidueConfig(p *ogg.Packet) ([]residueConfig, error) {
+func readResidueConfig(p *ogg.Packet, codebooks []codebook) ([]residueConfig, error) {
 	tmp, err := p.GetUint(6)
 	if err != nil {
 		return nil, err
@@ -29,7 +31,7 @@ func readResidueConfig(p *ogg.Packet) ([]residueConfig, error) {
 		}
 
 		if residueType < 3 {
-			cfg, err := readResidueHeader(p)
+			cfg, err := readResidueHeader(p, codebooks)
 			if err != nil {
 				return nil, err
 			}
@@ -42,11 +44,20 @@ func readResidueConfig(p *ogg.Packet) ([]residueConfig, error) {
 	return configs, nil
 }
 
-func readResidueHeader(p *ogg.Packet) (_ residueConfig, err error) {
+func readResidueHeader(p *ogg.Packet, codebooks []codebook) (_ residueConfig, err error) {
 	fields, err := p.GetUintSerial(24, 24, 24, 6, 8)
 	if err != nil {
 		return
 	}
+	if int(fields[4]) >= len(codebooks) {
+		err = fmt.Errorf("residue refers to undefined codebook %d", fields[4])
+		return
+	}
+	// classifications are unpacked by the dimension of the codebook
+	if codebooks[fields[4]].vqMap.dimension == 0 {
+		err = errors.New("residue classbook has zero dimension")
+		return
+	}
 	clsLen := fields[3] + 1
 	cascade := make([]uint8, clsLen)
 	for i := range cascade {
@@ -77,6 +88,15 @@ func readResidueHeader(p *ogg.Packet) (_ residueConfig, err error) {
 				if err != nil {
 					return
 				}
+				book := residueBooks[i][j]
+				if book >= len(codebooks) {
+					err = fmt.Errorf("residue refers to undefined codebook %d", book)
+					return
+				}
+				if codebooks[book].vqMap.lookupType == 0 {
+					err = fmt.Errorf("residue refers to scalar codebook %d", book)
+					return
+				}
 			} else {
 				// unused
 				residueBooks[i][j] = -1
@@ -94,70 +114,80 @@ func readResidueHeader(p *ogg.Packet) (_ residueConfig, err error) {
 	}, nil
 }
 
-func readResiduePacket(p *ogg.Packet, blockExp int, config residueConfig, codebooks []codebook, noDecodeFlags []bool) ([][]float64, error) {
-	n := 1 << blockExp
+// residueBuffer holds working memory for residue decode.
+type residueBuffer[S sample] struct {
+	interleaved []S
+	partClasses [][]int
+	vector      []S
+	lookup      []float64
+}
+
+// readResiduePacket decodes residue vectors of length n into resVectors, one for each channel of noDecodeFlags.
+func readResiduePacket[S sample](p *ogg.Packet, n int, config residueConfig, codebooks []codebook, noDecodeFlags []bool, resVectors [][]S, buf *residueBuffer[S]) error {
 	chNum := len(noDecodeFlags)
-	if config.residueType == 2 {
-		flag := true
-		for _, v := range noDecodeFlags {
-			flag = flag && v
+	if config.residueType != 2 {
+		for i := range resVectors {
+			resVectors[i] = resVectors[i][:n]
 		}
-		n *= chNum
-		noDecodeFlags = []bool{flag}
+		return decodeCommonResiduePacket(p, n, config, codebooks, noDecodeFlags, resVectors, buf)
 	}
-	decoded, err := decodeCommonResiduePacket(p, n, config, codebooks, noDecodeFlags)
 
-	if err != nil {
-		return nil, err
+	flag := true
+	for _, v := range noDecodeFlags {
+		flag = flag && v
 	}
-	if config.residueType != 2 {
-		return decoded, nil
+	buf.interleaved = grow(buf.interleaved, n*chNum)
+	decoded := [][]S{buf.interleaved}
+	err := decodeCommonResiduePacket(p, n*chNum, config, codebooks, []bool{flag}, decoded, buf)
+	if err != nil {
+		return err
 	}
 
 	// de-interleave
-	vec := make([][]float64, chNum)
-	for i := range vec {
-		vec[i] = make([]float64, n/chNum)
+	for i := range resVectors {
+		resVectors[i] = resVectors[i][:n]
 	}
 	for i, val := range decoded[0] {
-		vec[i%chNum][i/chNum] = val
+		resVectors[i%chNum][i/chNum] = val
 	}
-	return vec, nil
+	return nil
 }
 
-func decodeCommonResiduePacket(p *ogg.Packet, n int, config residueConfig, codebooks []codebook, noDecodeFlags []bool) (_ [][]float64, err error) {
-	chNum := len(noDecodeFlags)
-	resVectors := make([][]float64, chNum)
+func decodeCommonResiduePacket[S sample](p *ogg.Packet, n int, config residueConfig, codebooks []codebook, noDecodeFlags []bool, resVectors [][]S, buf *residueBuffer[S]) error {
+	for i := range resVectors {
+		clear(resVectors[i])
+	}
 
 	begin := min(n, int(config.begin))
 	end := min(n, int(config.end))
 	readSize := end - begin
 	if readSize <= 0 {
-		for i := range resVectors {
-			resVectors[i] = make([]float64, n)
-		}
-		return resVectors, nil
+		return nil
 	}
 	partSize := int(config.partitionSize)
 	partNum := readSize / partSize
 	cwDim := int(codebooks[config.classBook].vqMap.dimension)
 
-	partClasses := make([][]int, chNum)
+	if len(buf.partClasses) < len(noDecodeFlags) {
+		buf.partClasses = make([][]int, len(noDecodeFlags))
+	}
+	partClasses := buf.partClasses
+	for i := range noDecodeFlags {
+		// classifications of the last codeword may exceed partNum
+		partClasses[i] = grow(partClasses[i], partNum+cwDim)
+	}
 
 	for phase := 0; phase < 8; phase++ {
 		partCount := 0
 		for partCount < partNum {
 			if phase == 0 { // read initial codeword
 				for ch, flag := range noDecodeFlags {
-					partClasses[ch] = make([]int, partNum) // ?
-					resVectors[ch] = make([]float64, n)
-
 					if flag {
 						continue
 					}
 					temp, err := codebooks[config.classBook].ReadScalarValue(p)
 					if err != nil {
-						return nil, err
+						return endOfResidue(err)
 					}
 					for i := cwDim - 1; i >= 0; i-- {
 						partClasses[ch][i+partCount] = temp % int(config.classLen)
@@ -165,7 +195,7 @@ func decodeCommonResiduePacket(p *ogg.Packet, n int, config residueConfig, codeb
 					}
 				}
 			}
-			for i := 0; i < cwDim; i++ {
+			for i := 0; i < cwDim && partCount < partNum; i++ {
 				for ch, flag := range noDecodeFlags {
 					if flag {
 						continue
@@ -175,53 +205,83 @@ func decodeCommonResiduePacket(p *ogg.Packet, n int, config residueConfig, codeb
 					if vqBookIndex == -1 { // unused
 						continue
 					}
-					vqBook := codebooks[vqBookIndex]
+					vqBook := &codebooks[vqBookIndex]
 					offset := begin + partCount*partSize
-					var partVec []float64
+					partVec := resVectors[ch][offset : offset+partSize]
+					buf.vector = grow(buf.vector, int(vqBook.vqMap.dimension))
+					buf.lookup = grow(buf.lookup, int(vqBook.vqMap.dimension))
+
+					var err error
 					if config.residueType == 0 {
-						partVec, err = decodeResidue0(p, vqBook, partSize)
+						err = decodeResidue0(p, vqBook, partVec, buf)
 					} else {
-						partVec, err = decodeResidue1(p, vqBook, partSize)
+						err = decodeResidue1(p, vqBook, partVec, buf)
 					}
 					if err != nil {
-						return nil, err
-					}
-					for j, v := range partVec {
-						resVectors[ch][offset+j] += v
+						return endOfResidue(err)
 					}
 				}
 				partCount++
 			}
 		}
 	}
-	return resVectors, nil
+	return nil
+}
+
+// endOfResidue handles error during residue decode.
+// reaching end of packet is not an error; vectors decoded so far are left as is.
+func endOfResidue(err error) error {
+	if errors.Is(err, ogg.ErrEndOfPacket) {
+		return nil
+	}
+	return err
 }
 
-func decodeResidue0(p *ogg.Packet, vqBook codebook, partSize int) ([]float64, error) {
-	v := make([]float64, partSize)
+// decodeResidue0 adds interleaved VQ vectors to partVec.
+func decodeResidue0[S sample](p *ogg.Packet, vqBook *codebook, partVec []S, buf *residueBuffer[S]) error {
 	dim := int(vqBook.vqMap.dimension)
-	step := partSize / dim
+	step := len(partVec) / dim
+	vector := buf.vector
 	for i := 0; i < step; i++ {
-		tmp, err := vqBook.ReadVectorValue(p)
+		err := readVector(vqBook, p, vector, buf.lookup)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		for j := 0; j < dim; j++ {
-			v[i+step*j] = tmp[j]
+			partVec[i+step*j] += vector[j]
 		}
 	}
-	return v, nil
+	return nil
 }
 
-func decodeResidue1(p *ogg.Packet, vqBook codebook, partSize int) ([]float64, error) {
-	v := make([]float64, 0, partSize)
+// decodeResidue1 adds concatenated VQ vectors to partVec.
+func decodeResidue1[S sample](p *ogg.Packet, vqBook *codebook, partVec []S, buf *residueBuffer[S]) error {
 	dim := int(vqBook.vqMap.dimension)
-	for i := 0; i < partSize; i += dim {
-		tmp, err := vqBook.ReadVectorValue(p)
+	vector := buf.vector
+	for i := 0; i+dim <= len(partVec); i += dim {
+		err := readVector(vqBook, p, vector, buf.lookup)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		for j, v := range vector {
+			partVec[i+j] += v
 		}
-		v = append(v, tmp...)
 	}
-	return v, nil
+	return nil
+}
+
+// readVector reads a VQ vector into vector as the sample type, fixed point for int32.
+// lookup is the buffer for vectors not expanded in the table.
+func readVector[S sample](cb *codebook, p *ogg.Packet, vector []S, lookup []float64) error {
+	if v, ok := any(vector).([]int32); ok {
+		return cb.ReadFixedVectorValue(p, v)
+	}
+	v, err := cb.ReadVectorValue(p, lookup)
+	if err != nil {
+		return err
+	}
+	for i, val := range v {
+		vector[i] = S(val)
+	}
+	return nil
 }
@@ -0,0 +1,221 @@
+package vorbis
+
+import (
+	"encoding/json"
+	"math/bits"
+)
+
+// SetupInfo is a read-only view of the setup header, which describes how the stream is encoded.
+// It is a copy, so that modifying it does not affect decoding.
+type SetupInfo struct {
+	Codebooks []CodebookInfo `json:"codebooks"`
+	Floors    []FloorInfo    `json:"floors"`
+	Residues  []ResidueInfo  `json:"residues"`
+	Mappings  []MappingInfo  `json:"mappings"`
+	Modes     []ModeInfo     `json:"modes"`
+}
+
+type CodebookInfo struct {
+	Dimension int   `json:"dimension"`
+	Entries   int   `json:"entries"`
+	Ordered   bool  `json:"ordered"`
+	Sparse    bool  `json:"sparse"`
+	Lengths   []int `json:"lengths"` // codeword length of each entry, 0 for unused
+	// Lookup is nil for codebooks of scalar context (lookup type 0).
+	Lookup *VQLookupInfo `json:"lookup,omitempty"`
+}
+
+type VQLookupInfo struct {
+	Type    int     `json:"type"`
+	Minimum float64 `json:"minimum"`
+	Delta   float64 `json:"delta"`
+	// RawMinimum and RawDelta are Minimum and Delta in the 32 bit float format as read,
+	// which Encode writes back unless Minimum or Delta is modified, since a value has multiple encodings.
+	RawMinimum    uint32   `json:"rawMinimum"`
+	RawDelta      uint32   `json:"rawDelta"`
+	ValueBits     int      `json:"valueBits"`
+	SequenceP     bool     `json:"sequenceP"`
+	Multiplicands []uint32 `json:"multiplicands"`
+}
+
+type FloorInfo struct {
+	Type   int         `json:"type"`
+	Floor1 *Floor1Info `json:"floor1,omitempty"`
+}
+
+type Floor1Info struct {
+	Partitions []int             `json:"partitions"` // class of each partition
+	Classes    []Floor1ClassInfo `json:"classes"`
+	Multiplier int               `json:"multiplier"`
+	RangeBits  int               `json:"rangeBits"`
+	XList      []int             `json:"xList"` // including both ends
+}
+
+type Floor1ClassInfo struct {
+	Dimension     int   `json:"dimension"`
+	SubclassBits  int   `json:"subclassBits"`
+	MasterBook    int   `json:"masterBook"`    // -1 if no subclass
+	SubclassBooks []int `json:"subclassBooks"` // -1 for unused
+}
+
+type ResidueInfo struct {
+	Type            int      `json:"type"`
+	Begin           int      `json:"begin"`
+	End             int      `json:"end"`
+	PartitionSize   int      `json:"partitionSize"`
+	Classifications int      `json:"classifications"`
+	ClassBook       int      `json:"classBook"`
+	Cascades        []int    `json:"cascades"` // bitmap of passes for each classification
+	Books           [][8]int `json:"books"`    // codebook of each pass for each classification, -1 for unused
+}
+
+type MappingInfo struct {
+	Coupling []CouplingInfo `json:"coupling"`
+	Mux      []int          `json:"mux"` // submap of each channel
+	Submaps  []SubmapInfo   `json:"submaps"`
+}
+
+type CouplingInfo struct {
+	Magnitude int `json:"magnitude"`
+	Angle     int `json:"angle"`
+}
+
+type SubmapInfo struct {
+	Floor   int `json:"floor"`
+	Residue int `json:"residue"`
+}
+
+type ModeInfo struct {
+	BlockFlag bool `json:"blockFlag"`
+	Mapping   int  `json:"mapping"`
+}
+
+// Info returns the inspectable view of the setup header.
+func (vs VorbisSetup) Info() SetupInfo {
+	info := SetupInfo{
+		Codebooks: make([]CodebookInfo, len(vs.codebooks)),
+		Floors:    make([]FloorInfo, len(vs.floorConfigs)),
+		Residues:  make([]ResidueInfo, len(vs.residueConfigs)),
+		Mappings:  make([]MappingInfo, len(vs.mappingConfigs)),
+		Modes:     make([]ModeInfo, len(vs.modeConfigs)),
+	}
+	for i, cb := range vs.codebooks {
+		info.Codebooks[i] = cb.info()
+	}
+	for i, fc := range vs.floorConfigs {
+		info.Floors[i] = FloorInfo{Type: int(fc.floorType)}
+		if fc.config1 != nil {
+			info.Floors[i].Floor1 = fc.config1.info()
+		}
+	}
+	for i, rc := range vs.residueConfigs {
+		info.Residues[i] = rc.info()
+	}
+	for i, mc := range vs.mappingConfigs {
+		info.Mappings[i] = mc.info()
+	}
+	for i, mode := range vs.modeConfigs {
+		info.Modes[i] = ModeInfo{BlockFlag: mode.blockFlag, Mapping: int(mode.mapping)}
+	}
+	return info
+}
+
+// MarshalJSON encodes the view returned by Info.
+func (vs VorbisSetup) MarshalJSON() ([]byte, error) {
+	return json.Marshal(vs.Info())
+}
+
+func (cb *codebook) info() CodebookInfo {
+	vq := &cb.vqMap
+	info := CodebookInfo{
+		Dimension: int(vq.dimension),
+		Entries:   len(cb.lengths),
+		Ordered:   cb.ordered,
+		Sparse:    cb.sparse,
+		Lengths:   make([]int, len(cb.lengths)),
+	}
+	for i, l := range cb.lengths {
+		info.Lengths[i] = max(l, 0)
+	}
+	if vq.lookupType != 0 {
+		info.Lookup = &VQLookupInfo{
+			Type:          int(vq.lookupType),
+			Minimum:       vq.minimum,
+			Delta:         vq.delta,
+			RawMinimum:    vq.rawMinimum,
+			RawDelta:      vq.rawDelta,
+			ValueBits:     int(vq.valueBits),
+			SequenceP:     vq.seqFlag,
+			Multiplicands: append([]uint32(nil), vq.multiplicands...),
+		}
+	}
+	return info
+}
+
+func (fc *floor1Config) info() *Floor1Info {
+	info := &Floor1Info{
+		Partitions: make([]int, len(fc.partitions)),
+		Classes:    make([]Floor1ClassInfo, len(fc.classes)),
+		Multiplier: int(fc.multiplier),
+		RangeBits:  bits.Len16(fc.xList[1]) - 1,
+		XList:      make([]int, len(fc.xList)),
+	}
+	for i, v := range fc.partitions {
+		info.Partitions[i] = int(v)
+	}
+	for i, cls := range fc.classes {
+		master := -1
+		if cls.subclassNum != 0 {
+			master = int(cls.masterBook)
+		}
+		info.Classes[i] = Floor1ClassInfo{
+			Dimension:     int(cls.dimension),
+			SubclassBits:  int(cls.subclassNum),
+			MasterBook:    master,
+			SubclassBooks: append([]int(nil), cls.subBooks...),
+		}
+	}
+	for i, x := range fc.xList {
+		info.XList[i] = int(x)
+	}
+	return info
+}
+
+func (rc *residueConfig) info() ResidueInfo {
+	info := ResidueInfo{
+		Type:            int(rc.residueType),
+		Begin:           int(rc.begin),
+		End:             int(rc.end),
+		PartitionSize:   int(rc.partitionSize),
+		Classifications: int(rc.classLen),
+		ClassBook:       int(rc.classBook),
+		Cascades:        make([]int, len(rc.residueBooks)),
+		Books:           append([][8]int(nil), rc.residueBooks...),
+	}
+	for i, books := range rc.residueBooks {
+		for j, book := range books {
+			if book >= 0 {
+				info.Cascades[i] |= 1 << j
+			}
+		}
+	}
+	return info
+}
+
+func (mc *mappingConfig) info() MappingInfo {
+	info := MappingInfo{
+		Coupling: make([]CouplingInfo, len(mc.polarMap)),
+		Mux:      make([]int, len(mc.mapMux)),
+		Submaps:  make([]SubmapInfo, len(mc.submaps)),
+	}
+	for i, v := range mc.polarMap {
+		info.Coupling[i] = CouplingInfo{Magnitude: int(v[0]), Angle: int(v[1])}
+	}
+	for i, v := range mc.mapMux {
+		info.Mux[i] = int(v)
+	}
+	for i, sm := range mc.submaps {
+		info.Submaps[i] = SubmapInfo{Floor: int(sm.floor), Residue: int(sm.residue)}
+	}
+	return info
+}
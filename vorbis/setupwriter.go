@@ -0,0 +1,285 @@
+package vorbis
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+// setupWriter writes fields of setup header, keeping the first error of values out of range.
+type setupWriter struct {
+	w   ogg.BitWriter
+	err error
+}
+
+func (sw *setupWriter) put(v int, n uint32, what string) {
+	if sw.err != nil {
+		return
+	}
+	if v < 0 || (n < 32 && v >= 1<<n) {
+		sw.err = fmt.Errorf("%s out of range: %d", what, v)
+		return
+	}
+	sw.w.PutUint(uint32(v), n)
+}
+
+func (sw *setupWriter) fail(err error) {
+	if sw.err == nil {
+		sw.err = err
+	}
+}
+
+// Encode writes the setup header packet described by info, for the stream of ident.
+// A setup read by Info is encoded into identical bytes.
+func (info SetupInfo) Encode(ident Identification) ([]byte, error) {
+	var sw setupWriter
+	sw.put(5, 8, "packet type")
+	sw.w.PutBytes([]byte("vorbis"))
+
+	sw.put(len(info.Codebooks)-1, 8, "number of codebooks")
+	for i, cb := range info.Codebooks {
+		sw.writeCodebook(cb)
+		if sw.err != nil {
+			return nil, fmt.Errorf("codebook %d: %w", i, sw.err)
+		}
+	}
+
+	// time domain transforms, placeholder
+	sw.put(0, 6, "")
+	sw.put(0, 16, "")
+
+	sw.put(len(info.Floors)-1, 6, "number of floors")
+	for i, floor := range info.Floors {
+		sw.writeFloor(floor, len(info.Codebooks))
+		if sw.err != nil {
+			return nil, fmt.Errorf("floor %d: %w", i, sw.err)
+		}
+	}
+
+	sw.put(len(info.Residues)-1, 6, "number of residues")
+	for i, res := range info.Residues {
+		sw.writeResidue(res)
+		if sw.err != nil {
+			return nil, fmt.Errorf("residue %d: %w", i, sw.err)
+		}
+	}
+
+	sw.put(len(info.Mappings)-1, 6, "number of mappings")
+	for i, m := range info.Mappings {
+		sw.writeMapping(m, int(ident.Channels))
+		if sw.err != nil {
+			return nil, fmt.Errorf("mapping %d: %w", i, sw.err)
+		}
+	}
+
+	sw.put(len(info.Modes)-1, 6, "number of modes")
+	for _, mode := range info.Modes {
+		sw.w.PutFlag(mode.BlockFlag)
+		sw.put(0, 16, "") // window type
+		sw.put(0, 16, "") // transform type
+		sw.put(mode.Mapping, 8, "mode mapping")
+	}
+	sw.w.PutFlag(true) // framing
+
+	if sw.err != nil {
+		return nil, sw.err
+	}
+	return sw.w.Bytes(), nil
+}
+
+func (sw *setupWriter) writeCodebook(cb CodebookInfo) {
+	sw.put(0x564342, 24, "")
+	sw.put(cb.Dimension, 16, "dimension")
+	sw.put(cb.Entries, 24, "number of entries")
+	if len(cb.Lengths) != cb.Entries {
+		sw.fail(errors.New("number of codeword lengths mismatch"))
+		return
+	}
+	if cb.Entries == 0 {
+		sw.fail(errors.New("codebook has no entries"))
+		return
+	}
+
+	sw.w.PutFlag(cb.Ordered)
+	if cb.Ordered {
+		curLen := cb.Lengths[0]
+		sw.put(curLen-1, 5, "codeword length")
+		for i := 0; i < cb.Entries; curLen++ {
+			num := 0
+			for i+num < cb.Entries && cb.Lengths[i+num] == curLen {
+				num++
+			}
+			if num == 0 && (cb.Lengths[i] < curLen || curLen >= 32) {
+				sw.fail(errors.New("codeword lengths of ordered codebook must be increasing"))
+				return
+			}
+			sw.put(num, fls(cb.Entries-i), "number of entries")
+			i += num
+		}
+	} else {
+		sw.w.PutFlag(cb.Sparse)
+		for _, l := range cb.Lengths {
+			if cb.Sparse {
+				sw.w.PutFlag(l > 0)
+				if l == 0 {
+					continue
+				}
+			}
+			sw.put(l-1, 5, "codeword length")
+		}
+	}
+
+	lu := cb.Lookup
+	if lu == nil {
+		sw.put(0, 4, "")
+		return
+	}
+	if lu.Type != 1 && lu.Type != 2 {
+		sw.fail(fmt.Errorf("invalid VQ type %d", lu.Type))
+		return
+	}
+	sw.put(lu.Type, 4, "")
+	for _, param := range []struct {
+		f   float64
+		raw uint32
+	}{{lu.Minimum, lu.RawMinimum}, {lu.Delta, lu.RawDelta}} {
+		// the value as read is kept, whose mantissa may not be normalized
+		v, ok := param.raw, toFloat(param.raw) == param.f
+		if !ok {
+			v, ok = fromFloat(param.f)
+		}
+		if !ok {
+			sw.fail(fmt.Errorf("cannot encode %g in VQ parameter", param.f))
+			return
+		}
+		sw.w.PutUint(v, 32)
+	}
+	sw.put(lu.ValueBits-1, 4, "bits of VQ values")
+	sw.w.PutFlag(lu.SequenceP)
+
+	lookupLen := cb.Dimension * cb.Entries
+	if lu.Type == 1 {
+		lookupLen = lookup1Values(uint16(cb.Dimension), uint32(cb.Entries))
+	}
+	if len(lu.Multiplicands) != lookupLen {
+		sw.fail(fmt.Errorf("VQ lookup needs %d values, got %d", lookupLen, len(lu.Multiplicands)))
+		return
+	}
+	for _, v := range lu.Multiplicands {
+		sw.put(int(v), uint32(lu.ValueBits), "VQ value")
+	}
+}
+
+func (sw *setupWriter) writeFloor(floor FloorInfo, cbLen int) {
+	f1 := floor.Floor1
+	if floor.Type != 1 || f1 == nil {
+		sw.fail(fmt.Errorf("%w: %d", ErrUnsupportedFloor, floor.Type))
+		return
+	}
+	sw.put(1, 16, "")
+
+	sw.put(len(f1.Partitions), 5, "number of partitions")
+	for _, cls := range f1.Partitions {
+		sw.put(cls, 4, "partition class")
+	}
+	clsLen := 0
+	if len(f1.Partitions) > 0 {
+		clsLen = slices.Max(f1.Partitions) + 1
+	}
+	if len(f1.Classes) != clsLen {
+		sw.fail(fmt.Errorf("partitions need %d classes, got %d", clsLen, len(f1.Classes)))
+		return
+	}
+	points := 0
+	for _, cls := range f1.Partitions {
+		points += f1.Classes[cls].Dimension
+	}
+	for _, cls := range f1.Classes {
+		sw.put(cls.Dimension-1, 3, "class dimension")
+		sw.put(cls.SubclassBits, 2, "subclass bits")
+		if cls.SubclassBits != 0 {
+			sw.put(cls.MasterBook, 8, "master book")
+		}
+		if len(cls.SubclassBooks) != 1<<cls.SubclassBits {
+			sw.fail(errors.New("number of subclass books mismatch"))
+			return
+		}
+		for _, book := range cls.SubclassBooks {
+			if book >= cbLen {
+				sw.fail(fmt.Errorf("floor refers to undefined codebook %d", book))
+				return
+			}
+			sw.put(book+1, 8, "subclass book")
+		}
+	}
+	sw.put(f1.Multiplier-1, 2, "multiplier")
+	sw.put(f1.RangeBits, 4, "range bits")
+	if len(f1.XList) != points+2 {
+		sw.fail(fmt.Errorf("partitions need %d x values, got %d", points+2, len(f1.XList)))
+		return
+	}
+	for _, x := range f1.XList[2:] {
+		sw.put(x, uint32(f1.RangeBits), "x value")
+	}
+}
+
+func (sw *setupWriter) writeResidue(res ResidueInfo) {
+	sw.put(res.Type, 16, "residue type")
+	sw.put(res.Begin, 24, "residue begin")
+	sw.put(res.End, 24, "residue end")
+	sw.put(res.PartitionSize-1, 24, "partition size")
+	sw.put(res.Classifications-1, 6, "number of classifications")
+	sw.put(res.ClassBook, 8, "class book")
+	if len(res.Cascades) != res.Classifications || len(res.Books) != res.Classifications {
+		sw.fail(errors.New("number of cascades mismatch"))
+		return
+	}
+	for _, cascade := range res.Cascades {
+		sw.put(cascade&7, 3, "")
+		sw.w.PutFlag(cascade>>3 != 0)
+		if cascade>>3 != 0 {
+			sw.put(cascade>>3, 5, "cascade")
+		}
+	}
+	for i, books := range res.Books {
+		for j, book := range books {
+			if (res.Cascades[i]>>j)&1 == 1 {
+				sw.put(book, 8, "residue book")
+			}
+		}
+	}
+}
+
+func (sw *setupWriter) writeMapping(m MappingInfo, chNum int) {
+	sw.put(0, 16, "")
+	sw.w.PutFlag(len(m.Submaps) > 1)
+	if len(m.Submaps) > 1 {
+		sw.put(len(m.Submaps)-1, 4, "number of submaps")
+	}
+	sw.w.PutFlag(len(m.Coupling) > 0)
+	if len(m.Coupling) > 0 {
+		sw.put(len(m.Coupling)-1, 8, "coupling steps")
+		b := fls(chNum - 1)
+		for _, c := range m.Coupling {
+			sw.put(c.Magnitude, b, "magnitude channel")
+			sw.put(c.Angle, b, "angle channel")
+		}
+	}
+	sw.put(0, 2, "") // reserved
+	if len(m.Submaps) > 1 {
+		if len(m.Mux) != chNum {
+			sw.fail(errors.New("number of mux values mismatch"))
+			return
+		}
+		for _, v := range m.Mux {
+			sw.put(v, 4, "submap mux")
+		}
+	}
+	for _, sm := range m.Submaps {
+		sw.put(0, 8, "") // time configuration, unused
+		sw.put(sm.Floor, 8, "submap floor")
+		sw.put(sm.Residue, 8, "submap residue")
+	}
+}
@@ -0,0 +1,84 @@
+package vorbis
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/sr8e/vorbis/ogg"
+)
+
+func TestSetupEncode(t *testing.T) {
+	vd := openTestDecoder(t)
+	if err := vd.ReadHeaders(); err != nil {
+		t.Fatal(err)
+	}
+	want := vd.Packets[2].Bytes()
+
+	info := vd.Setup().Info()
+	got, err := info.Encode(vd.Identification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encoded setup differs: %d bytes, want %d", len(got), len(want))
+	}
+
+	// so does the setup through JSON, as vorbisdump prints
+	b, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded SetupInfo
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	got, err = decoded.Encode(vd.Identification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("setup encoded from JSON differs")
+	}
+}
+
+func TestEncodeVQParameter(t *testing.T) {
+	// mantissa of 1 with large exponent is not normalized, which fromFloat never yields
+	raw := uint32(1 | (788+3)<<21)
+	cb := CodebookInfo{
+		Dimension: 1,
+		Entries:   2,
+		Lengths:   []int{1, 1},
+		Lookup: &VQLookupInfo{
+			Type:          2,
+			Minimum:       toFloat(raw),
+			RawMinimum:    raw,
+			Delta:         1,
+			ValueBits:     1,
+			Multiplicands: []uint32{0, 1},
+		},
+	}
+	for _, tt := range []struct {
+		name    string
+		minimum float64
+		want    uint32
+	}{
+		{"as read", toFloat(raw), raw},
+		{"modified", 16, 1<<20 | (788-16)<<21},
+	} {
+		cb.Lookup.Minimum = tt.minimum
+		var sw setupWriter
+		sw.writeCodebook(cb)
+		if sw.err != nil {
+			t.Fatal(sw.err)
+		}
+		p := ogg.NewPacket(sw.w.Bytes())
+		// sync pattern, dimension, entries, flags, lengths and lookup type precede the minimum
+		if _, err := p.GetUintSerial(24, 16, 24, 1, 1, 5, 5, 4); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := p.GetUint(32); got != tt.want {
+			t.Errorf("%s: minimum encoded as %08x, want %08x", tt.name, got, tt.want)
+		}
+	}
+}